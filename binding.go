@@ -0,0 +1,269 @@
+package main
+
+import (
+	// Standard library imports
+	"fmt"
+	"strings"
+
+	// Default imports (third-party packages not matching other prefixes)
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	// Imports with prefix github.com/crossplane
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/function-sdk-go/errors"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+
+	// Imports with prefix github.com/crossplane-contrib
+	"github.com/crossplane-contrib/provider-kubernetes/apis/object/v1alpha2"
+
+	"github.com/chelala/function-fluxcd-tenant-crossplane-providers-usage-resource-crbs/input/v1beta1"
+)
+
+// A binding binds a role of a ProviderRevision to the tenant.
+type binding struct {
+	// Name of the ClusterRoleBinding. It is also the external name and the
+	// composed resource name of the binding.
+	Name string
+
+	// Revision whose role is bound.
+	Revision boundRevision
+
+	// Role bound.
+	Role v1beta1.Role
+
+	// ClusterRole bound.
+	ClusterRole string
+
+	// Subjects the ClusterRole is bound to.
+	Subjects []rbacv1.Subject
+
+	// Rules of the ClusterRole generated for the binding. Nil unless the
+	// binding binds a ClusterRole generated by the function, in which case
+	// ClusterRoleResourceName is its composed resource name.
+	Rules                   []rbacv1.PolicyRule
+	ClusterRoleResourceName string
+}
+
+// planBindings returns a binding of every input role for every supplied
+// ProviderRevision to the supplied subjects, or to each of them when the input
+// asks for a binding per subject.
+func planBindings(in *v1beta1.Input, tenantName string, subjects []rbacv1.Subject, prs []boundRevision) ([]binding, error) {
+	groups := [][]rbacv1.Subject{subjects}
+	if in.BindingPerSubject {
+		groups = make([][]rbacv1.Subject, 0, len(subjects))
+		for _, s := range subjects {
+			groups = append(groups, []rbacv1.Subject{s})
+		}
+	}
+
+	out := make([]binding, 0, len(prs)*len(in.Roles)*len(groups))
+
+	// The binding each name was generated for.
+	names := map[string]binding{}
+
+	for _, pr := range prs {
+		for _, r := range in.Roles {
+			for _, subjects := range groups {
+				b, err := planBinding(in, tenantName, subjects, pr, r)
+				if err != nil {
+					return nil, err
+				}
+				if b == nil {
+					continue
+				}
+				for _, n := range []string{b.Name, b.ClusterRoleResourceName} {
+					if n == "" {
+						continue
+					}
+					if other, ok := names[n]; ok {
+						return nil, errors.Errorf("the %s binding of ProviderRevision %q and the %s binding of ProviderRevision %q are both named %q: the name template must generate a distinct name per provider and role", other.Role.Name, other.Revision.GetName(), r.Name, pr.GetName(), n)
+					}
+					names[n] = *b
+				}
+				out = append(out, *b)
+			}
+		}
+	}
+	return out, nil
+}
+
+// planBinding returns the binding of the supplied role of the supplied
+// ProviderRevision to the supplied subjects. It returns nil if the role has
+// nothing to grant for the ProviderRevision.
+func planBinding(in *v1beta1.Input, tenantName string, subjects []rbacv1.Subject, pr boundRevision, r v1beta1.Role) (*binding, error) {
+	d := templateData{
+		TenantName: tenantName,
+		Package:    pr.Package,
+		Revision:   pr.GetName(),
+		Role:       r.Name,
+	}
+
+	b := &binding{Revision: pr, Role: r, Subjects: subjects}
+
+	if r.Flavor == v1beta1.RoleFlavorProviderConfigUsage {
+		groups := providerConfigUsageGroups(pr.Unstructured)
+		if len(groups) == 0 {
+			// The provider has no ProviderConfigUsages to grant.
+			return nil, nil
+		}
+		b.Rules = []rbacv1.PolicyRule{{
+			APIGroups: groups,
+			Resources: []string{"providerconfigusages"},
+			Verbs:     r.Verbs,
+		}}
+	}
+
+	name, err := executeTemplate(in.NameTemplate, d)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot name the %s binding of ProviderRevision %q", r.Name, pr.GetName())
+	}
+	if !pr.Primary {
+		// Keep the binding of an inactive revision distinct from the one of
+		// the active revision of the same package.
+		name = fmt.Sprintf("%s-%s", name, strings.TrimPrefix(pr.GetName(), pr.Package+"-"))
+	}
+	if in.BindingPerSubject {
+		name = fmt.Sprintf("%s-%s", name, subjectNameSuffix(subjects[0]))
+	}
+	b.Name = shortenName(name, in.MaxNameLength)
+	if msgs := validation.IsDNS1123Subdomain(b.Name); len(msgs) > 0 {
+		return nil, errors.Errorf("nameTemplate %q generates the invalid name %q for the %s binding of ProviderRevision %q: %s", in.NameTemplate, b.Name, r.Name, pr.GetName(), strings.Join(msgs, "; "))
+	}
+	if b.Rules != nil {
+		b.ClusterRoleResourceName = shortenName(name+"-clusterrole", in.MaxNameLength)
+	}
+
+	switch r.Flavor {
+	case v1beta1.RoleFlavorEdit, v1beta1.RoleFlavorView:
+		b.ClusterRole = fmt.Sprintf("crossplane:provider:%s:aggregate-to-%s", pr.GetName(), strings.ToLower(string(r.Flavor)))
+	case v1beta1.RoleFlavorCustom:
+		if b.ClusterRole, err = executeTemplate(r.ClusterRole, d); err != nil {
+			return nil, errors.Wrapf(err, "cannot name the ClusterRole of the %s binding of ProviderRevision %q", r.Name, pr.GetName())
+		}
+		if msgs := validateClusterRoleName(b.ClusterRole); len(msgs) > 0 {
+			return nil, errors.Errorf("clusterRole %q of role %s generates the invalid name %q for ProviderRevision %q: %s", r.ClusterRole, r.Name, b.ClusterRole, pr.GetName(), strings.Join(msgs, "; "))
+		}
+	case v1beta1.RoleFlavorProviderConfigUsage:
+		// The generated ClusterRole is named like its binding.
+		b.ClusterRole = b.Name
+	}
+
+	return b, nil
+}
+
+// validateTenantName returns an error explaining why the supplied tenant name
+// cannot be used as the name and namespace of the tenant ServiceAccount.
+func validateTenantName(name string) error {
+	if msgs := validation.IsDNS1123Label(name); len(msgs) > 0 {
+		return errors.Errorf("tenant name %q is used as a namespace and ServiceAccount name: %s", name, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// validateClusterRoleName returns why the supplied name of an existing
+// ClusterRole is invalid. Like the API server, it only requires RBAC names to
+// be path segments, so names like system:controller:foo_bar are valid.
+func validateClusterRoleName(name string) []string {
+	if name == "" {
+		return []string{"may not be empty"}
+	}
+	return path.IsValidPathSegmentName(name)
+}
+
+// clusterRoleBinding returns a ClusterRoleBinding of the supplied ClusterRole
+// to the supplied subjects.
+func clusterRoleBinding(name string, labels map[string]string, clusterRole string, subjects []rbacv1.Subject) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       "ClusterRoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterRole,
+		},
+		Subjects: subjects,
+	}
+}
+
+// clusterRole returns a ClusterRole with the supplied rules.
+func clusterRole(name string, labels map[string]string, rules []rbacv1.PolicyRule) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       "ClusterRole",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Rules: rules,
+	}
+}
+
+// compose returns the supplied manifest in the unstructured resource data
+// format the SDK uses to store desired composed resources. In Object output
+// mode the manifest is wrapped in a provider-kubernetes Object. The composed
+// resource is labelled with the name of the binding it belongs to.
+func compose(in *v1beta1.Input, externalName, bindingName string, manifest runtime.Object) (*composed.Unstructured, error) {
+	m, err := composed.From(manifest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot convert %T to %T", manifest, m)
+	}
+	if in.Output == v1beta1.OutputModeDirect {
+		l := m.GetLabels()
+		if l == nil {
+			l = map[string]string{}
+		}
+		l[labelBinding] = bindingName
+		m.SetLabels(l)
+		return m, nil
+	}
+	raw, err := m.MarshalJSON()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal %T", manifest)
+	}
+
+	o := &v1alpha2.Object{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				labelBinding: bindingName,
+			},
+			Annotations: map[string]string{
+				"crossplane.io/external-name": externalName,
+			},
+		},
+		Spec: v1alpha2.ObjectSpec{
+			ForProvider: v1alpha2.ObjectParameters{
+				Manifest: runtime.RawExtension{Raw: raw},
+			},
+		},
+	}
+	if opts := in.Object; opts != nil {
+		if opts.ProviderConfigRef != nil {
+			o.Spec.ProviderConfigReference = &xpv1.Reference{Name: opts.ProviderConfigRef.Name}
+		}
+		for _, a := range opts.ManagementPolicies {
+			o.Spec.ManagementPolicies = append(o.Spec.ManagementPolicies, xpv1.ManagementAction(a))
+		}
+		o.Spec.DeletionPolicy = xpv1.DeletionPolicy(opts.DeletionPolicy)
+		if opts.Readiness != nil {
+			o.Spec.Readiness = v1alpha2.Readiness{
+				Policy:   v1alpha2.ReadinessPolicy(opts.Readiness.Policy),
+				CelQuery: opts.Readiness.CelQuery,
+			}
+		}
+		o.Spec.Watch = opts.Watch
+	}
+	u, err := composed.From(o)
+	return u, errors.Wrapf(err, "cannot convert %T to %T", o, u)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	// Standard library imports
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+// Kinds of ProviderRevision discovery cache.
+const (
+	discoveryCacheTTL      = "ttl"
+	discoveryCacheInformer = "informer"
+)
+
+// Results of a ProviderRevision discovery cache lookup.
+const (
+	cacheResultHit  = "hit"
+	cacheResultMiss = "miss"
+)
+
+// discoveryCacheLookups counts ProviderRevision discovery cache lookups.
+var discoveryCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "function_fluxcd_tenant_crbs_discovery_cache_lookups_total",
+	Help: "Number of ProviderRevision discovery cache lookups, by cache and result.",
+}, []string{"cache", "result"})
+
+// A fetchFunc lists the ProviderRevisions in the cluster per the supplied
+// options.
+type fetchFunc func(ctx context.Context, log logging.Logger, o listOptions) (*unstructured.UnstructuredList, error)
+
+// A ttlCache caches the ProviderRevisions listed by a fetchFunc with each
+// listOptions for a fixed duration.
+type ttlCache struct {
+	fetch fetchFunc
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*ttlCacheEntry
+}
+
+// A ttlCacheEntry is locked while its ProviderRevisions are listed, so that
+// only callers of the same listOptions wait for the list.
+type ttlCacheEntry struct {
+	mu      sync.Mutex
+	list    *unstructured.UnstructuredList
+	expires time.Time
+}
+
+// newTTLCache returns a cache of the ProviderRevisions listed by the supplied
+// fetchFunc, listing them again once they are older than the supplied TTL.
+func newTTLCache(fetch fetchFunc, ttl time.Duration) *ttlCache {
+	return &ttlCache{fetch: fetch, ttl: ttl, now: time.Now, entries: map[string]*ttlCacheEntry{}}
+}
+
+// Fetch returns the cached ProviderRevisions, listing them when they expired.
+func (c *ttlCache) Fetch(ctx context.Context, log logging.Logger, o listOptions) (*unstructured.UnstructuredList, error) {
+	key := o.String()
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &ttlCacheEntry{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.list != nil && c.now().Before(e.expires) {
+		discoveryCacheLookups.WithLabelValues(discoveryCacheTTL, cacheResultHit).Inc()
+		return e.list.DeepCopy(), nil
+	}
+	discoveryCacheLookups.WithLabelValues(discoveryCacheTTL, cacheResultMiss).Inc()
+
+	l, err := c.fetch(ctx, log, o)
+	if err != nil {
+		return nil, err
+	}
+	e.list, e.expires = l, c.now().Add(c.ttl)
+	return l.DeepCopy(), nil
+}
+
+// An informerCache serves ProviderRevisions from an informer watching them.
+// It falls back to listing them until the informer has synced.
+type informerCache struct {
+	informer cache.SharedIndexInformer
+	fallback fetchFunc
+}
+
+// newInformerCache returns a cache of the ProviderRevisions watched with the
+// supplied client, resynced at the supplied period. It must be started.
+func newInformerCache(client dynamic.Interface, resync time.Duration, fallback fetchFunc) *informerCache {
+	f := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, resync, metav1.NamespaceAll, nil)
+	return &informerCache{informer: f.ForResource(providerRevisionsGVR).Informer(), fallback: fallback}
+}
+
+// Start watching ProviderRevisions until the supplied context is done.
+func (c *informerCache) Start(ctx context.Context) {
+	go c.informer.Run(ctx.Done())
+}
+
+// Fetch returns the watched ProviderRevisions, or lists them when the
+// informer has not synced yet. The informer watches every ProviderRevision in
+// full, so it returns the ProviderRevisions the options do not select too
+// when the options ask for their metadata.
+func (c *informerCache) Fetch(ctx context.Context, log logging.Logger, o listOptions) (*unstructured.UnstructuredList, error) {
+	if !c.informer.HasSynced() {
+		discoveryCacheLookups.WithLabelValues(discoveryCacheInformer, cacheResultMiss).Inc()
+		log.Debug("ProviderRevision informer has not synced, listing ProviderRevisions")
+		return c.fallback(ctx, log, o)
+	}
+	discoveryCacheLookups.WithLabelValues(discoveryCacheInformer, cacheResultHit).Inc()
+
+	objs := c.informer.GetStore().List()
+	l := &unstructured.UnstructuredList{Items: make([]unstructured.Unstructured, 0, len(objs))}
+	for _, obj := range objs {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			l.Items = append(l.Items, *u)
+		}
+	}
+	sort.Slice(l.Items, func(i, j int) bool { return l.Items[i].GetName() < l.Items[j].GetName() })
+	return selectList(l, o), nil
+}
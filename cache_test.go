@@ -0,0 +1,143 @@
+package main
+
+import (
+	// Standard library imports
+	"context"
+	"testing"
+	"time"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+// names returns the names of the supplied ProviderRevisions.
+func names(l *unstructured.UnstructuredList) []string {
+	n := make([]string, 0, len(l.Items))
+	for _, pr := range l.Items {
+		n = append(n, pr.GetName())
+	}
+	return n
+}
+
+func TestTTLCache(t *testing.T) {
+	calls := 0
+	fetch := func(_ context.Context, _ logging.Logger, _ listOptions) (*unstructured.UnstructuredList, error) {
+		calls++
+		pr := revision("provider-kubernetes-aaa", "provider-kubernetes", "Active", int64(calls), true)
+		return &unstructured.UnstructuredList{Items: []unstructured.Unstructured{pr}}, nil
+	}
+
+	now := time.Date(2024, 12, 12, 19, 0, 0, 0, time.UTC)
+	c := newTTLCache(fetch, time.Minute)
+	c.now = func() time.Time { return now }
+
+	steps := []struct {
+		reason    string
+		advance   time.Duration
+		wantCalls int
+	}{
+		{reason: "The first fetch should list ProviderRevisions.", wantCalls: 1},
+		{reason: "A fetch within the TTL should be served from the cache.", advance: 30 * time.Second, wantCalls: 1},
+		{reason: "A fetch after the TTL should list ProviderRevisions again.", advance: time.Minute, wantCalls: 2},
+	}
+	for _, s := range steps {
+		now = now.Add(s.advance)
+		l, err := c.Fetch(context.Background(), logging.NewNopLogger(), listOptions{})
+		if err != nil {
+			t.Fatalf("%s\nc.Fetch(...): %v", s.reason, err)
+		}
+		if diff := cmp.Diff(s.wantCalls, calls); diff != "" {
+			t.Errorf("%s\nc.Fetch(...): -want lists, +got lists:\n%s", s.reason, diff)
+		}
+		if rev, _, _ := unstructured.NestedInt64(l.Items[0].Object, "spec", "revision"); rev != int64(s.wantCalls) {
+			t.Errorf("%s\nc.Fetch(...): got revision %d from list %d", s.reason, rev, s.wantCalls)
+		}
+	}
+}
+
+func TestTTLCacheConcurrentKeys(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	fetch := func(_ context.Context, _ logging.Logger, o listOptions) (*unstructured.UnstructuredList, error) {
+		if o.Selector != nil {
+			// The selected list hangs until the test ends.
+			close(started)
+			<-release
+		}
+		return &unstructured.UnstructuredList{}, nil
+	}
+	c := newTTLCache(fetch, time.Minute)
+
+	if _, err := c.Fetch(context.Background(), logging.NewNopLogger(), listOptions{}); err != nil {
+		t.Fatalf("c.Fetch(...): %v", err)
+	}
+	go func() {
+		_, _ = c.Fetch(context.Background(), logging.NewNopLogger(), listOptions{Selector: labels.SelectorFromSet(labels.Set{"a": "b"})})
+	}()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = c.Fetch(context.Background(), logging.NewNopLogger(), listOptions{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("c.Fetch(...): a hanging list of other options should not block the cached ProviderRevisions")
+	}
+}
+
+func TestInformerCache(t *testing.T) {
+	prs := []runtime.Object{}
+	for _, pr := range []unstructured.Unstructured{
+		revision("provider-kubernetes-bbb", "provider-kubernetes", "Active", 1, true),
+		revision("provider-helm-aaa", "provider-helm", "Active", 1, true),
+	} {
+		prs = append(prs, pr.DeepCopy())
+	}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		providerRevisionsGVR: "ProviderRevisionList",
+	}, prs...)
+
+	fallbacks := 0
+	fallback := func(_ context.Context, _ logging.Logger, _ listOptions) (*unstructured.UnstructuredList, error) {
+		fallbacks++
+		return &unstructured.UnstructuredList{}, nil
+	}
+	c := newInformerCache(client, 0, fallback)
+
+	if _, err := c.Fetch(context.Background(), logging.NewNopLogger(), listOptions{}); err != nil {
+		t.Fatalf("c.Fetch(...): %v", err)
+	}
+	if fallbacks != 1 {
+		t.Errorf("c.Fetch(...): the cache should list ProviderRevisions until the informer has synced")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+	if !cache.WaitForCacheSync(ctx.Done(), c.informer.HasSynced) {
+		t.Fatal("the informer did not sync")
+	}
+
+	l, err := c.Fetch(context.Background(), logging.NewNopLogger(), listOptions{})
+	if err != nil {
+		t.Fatalf("c.Fetch(...): %v", err)
+	}
+	if diff := cmp.Diff([]string{"provider-helm-aaa", "provider-kubernetes-bbb"}, names(l)); diff != "" {
+		t.Errorf("c.Fetch(...): -want, +got:\n%s", diff)
+	}
+	if fallbacks != 1 {
+		t.Errorf("c.Fetch(...): the cache should not list ProviderRevisions once the informer has synced")
+	}
+}
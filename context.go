@@ -0,0 +1,64 @@
+package main
+
+import (
+	// Standard library imports
+	"encoding/json"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/function-sdk-go/errors"
+)
+
+// contextKeyBindings is the key of the pipeline context the function publishes
+// the bindings of the tenant under, for later steps of the pipeline.
+const contextKeyBindings = "apiextensions.crossplane.io/fluxcd-tenant-crbs"
+
+// A bindingsContext describes the bindings of a tenant to later steps of the
+// pipeline.
+type bindingsContext struct {
+	// TenantName of the tenant.
+	TenantName string `json:"tenantName"`
+
+	// Providers bound, in the order of their bindings.
+	Providers []providerContext `json:"providers"`
+
+	// Bindings of the tenant.
+	Bindings []bindingStatus `json:"bindings"`
+}
+
+// A providerContext describes a provider bound to the tenant.
+type providerContext struct {
+	// Package of the provider.
+	Package string `json:"package"`
+
+	// Revision is the name of the bound ProviderRevision.
+	Revision string `json:"revision"`
+}
+
+// newBindingsContext returns the pipeline context value describing the
+// supplied summaries of the bindings of the supplied tenant.
+func newBindingsContext(tenantName string, bindings []bindingStatus) (*structpb.Value, error) {
+	c := bindingsContext{
+		TenantName: tenantName,
+		Providers:  []providerContext{},
+		Bindings:   bindings,
+	}
+	seen := map[string]bool{}
+	for _, b := range bindings {
+		if b.Revision == "" || seen[b.Revision] {
+			continue
+		}
+		seen[b.Revision] = true
+		c.Providers = append(c.Providers, providerContext{Package: b.Package, Revision: b.Revision})
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal the bindings")
+	}
+	v := &structpb.Value{}
+	return v, errors.Wrap(protojson.Unmarshal(data, v), "cannot convert the bindings to a context value")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	// Standard library imports
+	"context"
+
+	// Default imports (third-party packages not matching other prefixes)
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/function-sdk-go/errors"
+
+	"github.com/chelala/function-fluxcd-tenant-crossplane-providers-usage-resource-crbs/input/v1beta1"
+)
+
+// providerRevisionsGVR is the resource of ProviderRevisions.
+var providerRevisionsGVR = schema.FromAPIVersionAndKind(providerRevisionAPIVersion, providerRevisionKind).GroupVersion().WithResource("providerrevisions")
+
+// listOptions configure how ProviderRevisions are listed.
+type listOptions struct {
+	// Selector of the ProviderRevisions listed in full. Every ProviderRevision
+	// is listed in full when it is nil.
+	Selector labels.Selector
+
+	// MetadataOnly also lists the names and labels of the ProviderRevisions
+	// the Selector does not select.
+	MetadataOnly bool
+}
+
+// String returns a key identifying the options.
+func (o listOptions) String() string {
+	s := labels.Everything().String()
+	if o.Selector != nil {
+		s = o.Selector.String()
+	}
+	if o.MetadataOnly {
+		s += ";metadata"
+	}
+	return s
+}
+
+// getListOptions returns how ProviderRevisions are listed per the supplied
+// input. Only the ProviderRevisions a server-side provider selector selects
+// are listed in full.
+func getListOptions(in *v1beta1.Input) (listOptions, error) {
+	ps := in.ProviderSelector
+	if ps == nil || !ps.ServerSide {
+		return listOptions{}, nil
+	}
+	sel, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchLabels: ps.MatchLabels, MatchExpressions: ps.MatchExpressions})
+	if err != nil {
+		return listOptions{}, errors.Wrap(err, "invalid label selector")
+	}
+	return listOptions{Selector: sel, MetadataOnly: true}, nil
+}
+
+// A lister lists ProviderRevisions from the API server, a page at a time.
+type lister struct {
+	client   dynamic.Interface
+	metadata metadata.Interface
+	pageSize int64
+}
+
+// getRESTConfig returns the config of the cluster discovery client. It uses
+// the in-cluster config unless a kubeconfig file or context is supplied, in
+// which case it loads the kubeconfig like kubectl, from the supplied file or
+// else from $KUBECONFIG or ~/.kube/config.
+func getRESTConfig(kubeconfig, kubecontext string) (*rest.Config, error) {
+	if kubeconfig == "" && kubecontext == "" {
+		config, err := rest.InClusterConfig()
+		return config, errors.Wrap(err, "cannot get in-cluster config")
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubecontext}).ClientConfig()
+	return config, errors.Wrap(err, "cannot load kubeconfig")
+}
+
+// newLister returns a lister using the supplied config, listing pages of the
+// supplied size. A size of 0 lists every ProviderRevision at once.
+func newLister(config *rest.Config, pageSize int64) (*lister, error) {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create dynamic client")
+	}
+	mc, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create metadata client")
+	}
+	return &lister{client: client, metadata: mc, pageSize: pageSize}, nil
+}
+
+// List lists ProviderRevisions per the supplied options. It lists the
+// ProviderRevisions the options select in full, and only the names and labels
+// of the others when the options ask for their metadata.
+func (l *lister) List(ctx context.Context, log logging.Logger, o listOptions) (*unstructured.UnstructuredList, error) {
+	sel := o.Selector
+	if sel == nil {
+		sel = labels.Everything()
+	}
+
+	out := &unstructured.UnstructuredList{}
+	opts := metav1.ListOptions{LabelSelector: sel.String(), Limit: l.pageSize}
+	for {
+		page, err := l.client.Resource(providerRevisionsGVR).List(ctx, opts)
+		if err != nil {
+			log.Info("Failed to list ProviderRevisions", "error", err)
+			return nil, errors.Wrap(err, "cannot list ProviderRevisions")
+		}
+		out.Items = append(out.Items, page.Items...)
+		if opts.Continue = page.GetContinue(); opts.Continue == "" {
+			break
+		}
+	}
+
+	if !o.MetadataOnly || sel.Empty() {
+		return out, nil
+	}
+
+	listed := map[string]bool{}
+	for _, pr := range out.Items {
+		listed[pr.GetName()] = true
+	}
+	opts = metav1.ListOptions{Limit: l.pageSize}
+	for {
+		page, err := l.metadata.Resource(providerRevisionsGVR).List(ctx, opts)
+		if err != nil {
+			log.Info("Failed to list ProviderRevision metadata", "error", err)
+			return nil, errors.Wrap(err, "cannot list ProviderRevision metadata")
+		}
+		for _, m := range page.Items {
+			if listed[m.GetName()] {
+				continue
+			}
+			pr := unstructured.Unstructured{}
+			pr.SetAPIVersion(providerRevisionAPIVersion)
+			pr.SetKind(providerRevisionKind)
+			pr.SetName(m.GetName())
+			pr.SetLabels(m.GetLabels())
+			out.Items = append(out.Items, pr)
+		}
+		if opts.Continue = page.GetContinue(); opts.Continue == "" {
+			break
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	// Standard library imports
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	ktesting "k8s.io/client-go/testing"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+func TestListerList(t *testing.T) {
+	prs := []unstructured.Unstructured{
+		revision("provider-helm-aaa", "provider-helm", "Active", 1, true),
+		revision("provider-kubernetes-aaa", "provider-kubernetes", "Active", 1, true),
+		revision("provider-kubernetes-bbb", "provider-kubernetes", "Inactive", 0, true),
+	}
+
+	type want struct {
+		names []string
+		pages int
+	}
+
+	cases := map[string]struct {
+		reason string
+		opts   listOptions
+		want   want
+	}{
+		"Paged": {
+			reason: "Every ProviderRevision should be listed in full, a page at a time.",
+			want: want{
+				names: []string{"provider-helm-aaa", "provider-kubernetes-aaa", "provider-kubernetes-bbb"},
+				pages: 2,
+			},
+		},
+		"Selector": {
+			reason: "Only the selected ProviderRevisions should be listed.",
+			opts:   listOptions{Selector: labels.SelectorFromSet(labels.Set{labelPackage: "provider-kubernetes"})},
+			want: want{
+				names: []string{"provider-kubernetes-aaa", "provider-kubernetes-bbb"},
+				pages: 1,
+			},
+		},
+		"MetadataOnly": {
+			reason: "The metadata of the ProviderRevisions that are not selected should be listed too.",
+			opts:   listOptions{Selector: labels.SelectorFromSet(labels.Set{labelPackage: "provider-helm"}), MetadataOnly: true},
+			want: want{
+				names: []string{"provider-helm-aaa", "provider-kubernetes-aaa", "provider-kubernetes-bbb"},
+				pages: 1,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+				providerRevisionsGVR: "ProviderRevisionList",
+			})
+			pages := 0
+			client.PrependReactor("list", "providerrevisions", func(a ktesting.Action) (bool, runtime.Object, error) {
+				pages++
+				la := a.(ktesting.ListActionImpl)
+				sel := la.GetListRestrictions().Labels
+				matched := []unstructured.Unstructured{}
+				for _, pr := range prs {
+					if sel.Matches(labels.Set(pr.GetLabels())) {
+						matched = append(matched, pr)
+					}
+				}
+				// Serve a page of two ProviderRevisions per call, as if
+				// every call continued the previous one.
+				start := min(2*(pages-1), len(matched))
+				end := min(start+2, len(matched))
+				l := &unstructured.UnstructuredList{Items: matched[start:end]}
+				if end < len(matched) {
+					l.SetContinue("more")
+				}
+				return true, l, nil
+			})
+
+			objs := []runtime.Object{}
+			for _, pr := range prs {
+				objs = append(objs, &metav1.PartialObjectMetadata{
+					TypeMeta:   metav1.TypeMeta{APIVersion: providerRevisionAPIVersion, Kind: providerRevisionKind},
+					ObjectMeta: metav1.ObjectMeta{Name: pr.GetName(), Labels: pr.GetLabels()},
+				})
+			}
+			scheme := metadatafake.NewTestScheme()
+			scheme.AddKnownTypeWithName(providerRevisionsGVR.GroupVersion().WithKind(providerRevisionKind), &metav1.PartialObjectMetadata{})
+			l := &lister{client: client, metadata: metadatafake.NewSimpleMetadataClient(scheme, objs...), pageSize: 2}
+
+			got, err := l.List(context.Background(), logging.NewNopLogger(), tc.opts)
+			if err != nil {
+				t.Fatalf("%s\nl.List(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.names, names(got)); diff != "" {
+				t.Errorf("%s\nl.List(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.pages, pages); diff != "" {
+				t.Errorf("%s\nl.List(...): -want pages, +got pages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetRESTConfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: kind-dev
+  cluster:
+    server: https://127.0.0.1:6443
+- name: kind-staging
+  cluster:
+    server: https://127.0.0.1:7443
+contexts:
+- name: kind-dev
+  context:
+    cluster: kind-dev
+- name: kind-staging
+  context:
+    cluster: kind-staging
+current-context: kind-dev
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		reason  string
+		context string
+		want    string
+	}{
+		"CurrentContext": {
+			reason: "The current context of the kubeconfig should be used by default.",
+			want:   "https://127.0.0.1:6443",
+		},
+		"Context": {
+			reason:  "The supplied context of the kubeconfig should be used.",
+			context: "kind-staging",
+			want:    "https://127.0.0.1:7443",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config, err := getRESTConfig(kubeconfig, tc.context)
+			if err != nil {
+				t.Fatalf("%s\ngetRESTConfig(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, config.Host); diff != "" {
+				t.Errorf("%s\ngetRESTConfig(...): -want host, +got host:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	// Standard library imports
+	"encoding/json"
+	"path"
+	"strings"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"google.golang.org/protobuf/encoding/protojson"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/function-sdk-go/errors"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/request"
+	"github.com/crossplane/function-sdk-go/resource/composite"
+)
+
+// An entitlement is a provider a tenant is entitled to, read from the
+// composite resource or the pipeline context. It is either a package name, which may be a glob, or an
+// object with a package and labels selecting ProviderRevisions.
+type entitlement struct {
+	Package     string            `json:"package,omitempty"`
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+// UnmarshalJSON unmarshals an entitlement from either a package name or an
+// object.
+func (e *entitlement) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.Package); err == nil {
+		return nil
+	}
+	type object entitlement
+	return json.Unmarshal(data, (*object)(e))
+}
+
+// String describes the entitlement.
+func (e entitlement) String() string {
+	if len(e.MatchLabels) == 0 {
+		return e.Package
+	}
+	sel := labels.Set(e.MatchLabels).String()
+	if e.Package == "" {
+		return sel
+	}
+	return e.Package + " (" + sel + ")"
+}
+
+// Matches returns true if the supplied ProviderRevision is entitled.
+func (e entitlement) Matches(pr *unstructured.Unstructured) bool {
+	l := pr.GetLabels()
+	if e.Package != "" {
+		if ok, _ := path.Match(e.Package, l[labelPackage]); !ok {
+			return false
+		}
+	}
+	return labels.SelectorFromSet(e.MatchLabels).Matches(labels.Set(l))
+}
+
+// validate returns an error explaining why the entitlement is invalid.
+func (e entitlement) validate() error {
+	if e.Package == "" && len(e.MatchLabels) == 0 {
+		return errors.New("an entitlement must have a package or matchLabels")
+	}
+	if _, err := path.Match(e.Package, ""); err != nil {
+		return errors.Wrapf(err, "invalid package glob %q", e.Package)
+	}
+	for k, v := range e.MatchLabels {
+		if msgs := validation.IsQualifiedName(k); len(msgs) > 0 {
+			return errors.Errorf("invalid label key %q: %s", k, strings.Join(msgs, "; "))
+		}
+		if msgs := validation.IsValidLabelValue(v); len(msgs) > 0 {
+			return errors.Errorf("invalid value %q of label %q: %s", v, k, strings.Join(msgs, "; "))
+		}
+	}
+	return nil
+}
+
+// getEntitlements returns the providers the tenant is entitled to, read from
+// the supplied composite resource at the supplied field path. It returns nil
+// when every provider is entitled: when the field path is empty or the
+// composite resource has no such field.
+func getEntitlements(xr *composite.Unstructured, fieldPath string) ([]entitlement, error) {
+	if fieldPath == "" {
+		return nil, nil
+	}
+	ents := []entitlement{}
+	if err := xr.GetValueInto(fieldPath, &ents); err != nil {
+		if fieldpath.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "cannot get the tenant providers at %s", fieldPath)
+	}
+	if err := validateEntitlements(ents, fieldPath); err != nil {
+		return nil, err
+	}
+	return ents, nil
+}
+
+// getContextEntitlements returns the providers the tenant is entitled to,
+// read from the pipeline context of the supplied request at the supplied key.
+// It returns nil when every provider is entitled: when the key is empty or the
+// context has no such key.
+func getContextEntitlements(req *fnv1.RunFunctionRequest, key string) ([]entitlement, error) {
+	if key == "" {
+		return nil, nil
+	}
+	v, ok := request.GetContextKey(req, key)
+	if !ok {
+		return nil, nil
+	}
+	data, err := protojson.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get the tenant providers at context key %s", key)
+	}
+	ents := []entitlement{}
+	if err := json.Unmarshal(data, &ents); err != nil {
+		return nil, errors.Wrapf(err, "cannot get the tenant providers at context key %s", key)
+	}
+	if err := validateEntitlements(ents, "context key "+key); err != nil {
+		return nil, err
+	}
+	return ents, nil
+}
+
+// validateEntitlements returns an error explaining why an entitlement read
+// from the supplied location is invalid.
+func validateEntitlements(ents []entitlement, from string) error {
+	for i, e := range ents {
+		if err := e.validate(); err != nil {
+			return errors.Wrapf(err, "invalid tenant provider %d at %s", i, from)
+		}
+	}
+	return nil
+}
+
+// entitled returns the supplied ProviderRevisions matching any of the supplied
+// entitlements. Every ProviderRevision is returned when ents is nil.
+func entitled(prs []unstructured.Unstructured, ents []entitlement) []unstructured.Unstructured {
+	if ents == nil {
+		return prs
+	}
+	out := make([]unstructured.Unstructured, 0, len(prs))
+	for i := range prs {
+		for _, e := range ents {
+			if e.Matches(&prs[i]) {
+				out = append(out, prs[i])
+				break
+			}
+		}
+	}
+	return out
+}
+
+// notInstalled returns the supplied entitlements no installed ProviderRevision
+// matches, in order.
+func notInstalled(installed []unstructured.Unstructured, ents []entitlement) []string {
+	missing := []string{}
+	for _, e := range ents {
+		found := false
+		for i := range installed {
+			if e.Matches(&installed[i]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, e.String())
+		}
+	}
+	return missing
+}
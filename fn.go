@@ -3,17 +3,13 @@ package main
 import (
 	// Standard library imports
 	"context"
-	"fmt"
+	"strings"
 
 	// Default imports (third-party packages not matching other prefixes)
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
+	rbacv1 "k8s.io/api/rbac/v1"
 
 	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/crossplane/function-sdk-go/errors"
 	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
@@ -24,14 +20,47 @@ import (
 
 	// Imports with prefix github.com/crossplane-contrib
 	"github.com/crossplane-contrib/provider-kubernetes/apis/object/v1alpha2"
+
+	"github.com/chelala/function-fluxcd-tenant-crossplane-providers-usage-resource-crbs/input/v1beta1"
+)
+
+const (
+	providerRevisionAPIVersion = "pkg.crossplane.io/v1"
+	providerRevisionKind       = "ProviderRevision"
+
+	// extraResourcesProviderRevisions is the key under which the function
+	// requires ProviderRevisions as extra resources.
+	extraResourcesProviderRevisions = "providerRevisions"
+
+	// conditionDiscovered tells whether the ProviderRevisions the bindings
+	// are derived from could be discovered.
+	conditionDiscovered   = "ProviderRevisionsDiscovered"
+	reasonDiscovered      = "Discovered"
+	reasonDiscoveryFailed = "DiscoveryFailed"
+
+	conditionBindingsReady = "BindingsReady"
+	reasonBindingsReady    = "Available"
+	reasonBindingsPending  = "Creating"
+	reasonBindingsFailing  = "Failing"
+
+	// Reasons of the events about changes to the bindings of the tenant.
+	reasonBindingsAdded     = "BindingsAdded"
+	reasonBindingsRemoved   = "BindingsRemoved"
+	reasonBindingsRetained  = "BindingsRetained"
+	reasonProvidersExcluded = "ProvidersExcluded"
+	reasonFamilyUnbound     = "FamilyConfigProvidersUnbound"
 )
 
 // Function returns whatever response you ask it to.
 type Function struct {
 	fnv1.UnimplementedFunctionRunnerServiceServer
 
-	log                        logging.Logger
-	fetchProviderRevisionsFunc func(ctx context.Context, log logging.Logger) (*unstructured.UnstructuredList, error)
+	log logging.Logger
+
+	// revisions supplies the ProviderRevisions of the cluster. The
+	// ProviderRevisions Crossplane supplies as extra resources are used when
+	// it is nil. Crossplane is only asked for them when they are used.
+	revisions RevisionSource
 }
 
 // RunFunction runs the Function.
@@ -40,11 +69,59 @@ func (f *Function) RunFunction(ctx context.Context, req *fnv1.RunFunctionRequest
 
 	rsp := response.To(req, response.DefaultTTL)
 
-	// Fetch ProviderRevisions using the new method
-	providerRevisions, err := f.fetchProviderRevisionsFunc(ctx, f.log)
+	in, err := getInput(req)
+	if err != nil {
+		response.Fatal(rsp, err)
+		return rsp, nil
+	}
+
+	opts, err := getListOptions(in)
+	if err != nil {
+		response.Fatal(rsp, errors.Wrap(err, "cannot evaluate the provider selector"))
+		return rsp, nil
+	}
+	var revisions RevisionSource = extraResourcesSource{}
+	if f.revisions != nil {
+		revisions = f.revisions
+	}
+	if in.RevisionsFile != "" {
+		fs, err := newFileSource(in.RevisionsFile)
+		if err != nil {
+			response.Fatal(rsp, errors.Wrap(err, "cannot read the ProviderRevisions of the input revisionsFile"))
+			return rsp, nil
+		}
+		revisions = fs
+	}
+	if _, ok := revisions.(extraResourcesSource); ok {
+		// Ask Crossplane for every ProviderRevision in the cluster. Crossplane
+		// calls the function again with the ProviderRevisions as extra
+		// resources. A configured source is the source of truth instead.
+		matchLabels := map[string]string{}
+		if ps := in.ProviderSelector; ps != nil && ps.ServerSide {
+			matchLabels = ps.MatchLabels
+		}
+		rsp.Requirements = &fnv1.Requirements{
+			ExtraResources: map[string]*fnv1.ResourceSelector{
+				extraResourcesProviderRevisions: {
+					ApiVersion: providerRevisionAPIVersion,
+					Kind:       providerRevisionKind,
+					Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: matchLabels}},
+				},
+			},
+		}
+	}
+	providerRevisions, err := revisions.ProviderRevisions(ctx, req, opts)
 	if err != nil {
 		f.log.Info("Failed to fetch ProviderRevisions", "error", err)
-		return nil, err
+		if in.OnDiscoveryFailure != v1beta1.DiscoveryFailureKeepObserved {
+			response.Fatal(rsp, errors.Wrap(err, "cannot discover ProviderRevisions"))
+			return rsp, nil
+		}
+		return keepObserved(req, rsp, in, err), nil
+	}
+	if providerRevisions == nil {
+		f.log.Debug("Waiting for Crossplane to supply ProviderRevisions as extra resources")
+		return rsp, nil
 	}
 
 	// Get all desired composed resources from the request. The function will
@@ -61,73 +138,104 @@ func (f *Function) RunFunction(ctx context.Context, req *fnv1.RunFunctionRequest
 		response.Fatal(rsp, errors.Wrapf(err, "cannot get the composite resource from %T", req))
 		return rsp, nil
 	}
-	tenantName, err := xr.Resource.GetString("spec.tenantName")
+	tenantName, err := xr.Resource.GetString(in.TenantFieldPath)
 	if err != nil {
 		response.Fatal(rsp, errors.Wrapf(err, "cannot get the XR tenant name from %T", req))
 		return rsp, nil
 	}
 
+	if err := validateTenantName(tenantName); err != nil {
+		response.Fatal(rsp, errors.Wrapf(err, "invalid XR tenant name at %s", in.TenantFieldPath))
+		return rsp, nil
+	}
+
+	subjects, err := getSubjects(xr.Resource, in, tenantName)
+	if err != nil {
+		response.Fatal(rsp, errors.Wrap(err, "cannot get the subjects of the tenant"))
+		return rsp, nil
+	}
+
+	ents, err := getEntitlements(xr.Resource, in.ProvidersFieldPath)
+	if err != nil {
+		response.Fatal(rsp, errors.Wrap(err, "cannot get the providers the tenant is entitled to"))
+		return rsp, nil
+	}
+	if ents == nil {
+		// Fall back to the providers an earlier step of the pipeline wrote.
+		if ents, err = getContextEntitlements(req, in.ProvidersContextKey); err != nil {
+			response.Fatal(rsp, errors.Wrap(err, "cannot get the providers the tenant is entitled to"))
+			return rsp, nil
+		}
+	}
+	if missing := notInstalled(providerRevisions.Items, ents); len(missing) > 0 {
+		response.Warning(rsp, errors.Errorf("providers requested by the tenant are not installed: %s", strings.Join(missing, ", ")))
+	}
+
+	filter, err := newProviderFilter(in.ProviderSelector)
+	if err != nil {
+		response.Fatal(rsp, errors.Wrap(err, "cannot evaluate the provider selector"))
+		return rsp, nil
+	}
+	candidates, excluded := filter.Filter(providerRevisions.Items)
+	candidates = entitled(candidates, ents)
+
+	// Members of a provider family need the family config provider, which
+	// owns the ProviderConfig and ProviderConfigUsage types of the family.
+	candidates, parents := withFamilyParents(candidates, providerRevisions.Items, filter.Denied)
+	prs := selectProviderRevisions(candidates, in.IncludeInactiveRevisions)
+	bound := map[string]bool{}
+	for _, pr := range prs {
+		bound[pr.Package] = true
+	}
+	for _, pkg := range parents {
+		if !bound[pkg] {
+			continue
+		}
+		f.log.Debug("Including provider family config provider of a selected member", "package", pkg)
+		delete(excluded, pkg)
+	}
+	if len(excluded) > 0 {
+		f.log.Debug("Excluded providers not matched by the provider selector", "excluded", excluded)
+		response.Warning(rsp, errors.Errorf("providers excluded by the provider selector: %s", excludedMessage(excluded))).
+			WithReason(reasonProvidersExcluded).
+			TargetCompositeAndClaim()
+	}
+	if unbound := unboundFamilyParents(prs, filter.Denied); len(unbound) > 0 {
+		response.Warning(rsp, errors.Errorf("family config providers of bound provider family members have no healthy revision to bind: %s", strings.Join(unbound, ", "))).
+			WithReason(reasonFamilyUnbound).
+			TargetCompositeAndClaim()
+	}
+
 	// Add object/v1alpha2 types (including object) to the composed resource scheme.
 	// composed. From uses this to automatically set apiVersion and kind.
 	_ = v1alpha2.SchemeBuilder.AddToScheme(composed.Scheme)
+	_ = rbacv1.AddToScheme(composed.Scheme)
+
+	bindings, err := planBindings(in, tenantName, subjects, prs)
+	if err != nil {
+		response.Fatal(rsp, err)
+		return rsp, nil
+	}
 
 	// 3. Process the results
-	for _, pr := range providerRevisions.Items {
+	for _, b := range bindings {
 		f.log.Info("XR tenant name", "tenantName", tenantName)
-		f.log.Info("Label pkg.crossplane.io/package", "providerName", pr.GetLabels()["pkg.crossplane.io/package"])
-		f.log.Info("ProviderRevision Name", "providerRevisionName", pr.GetName())
-
-		manifestFmt := []byte(`{
-		    "apiVersion": "rbac.authorization.k8s.io/v1",
-		    "kind": "ClusterRoleBinding",
-		    "metadata": {
-		        "labels": {
-		            "kustomize.toolkit.fluxcd.io/name": "tenants",
-		            "kustomize.toolkit.fluxcd.io/namespace": "flux-system"
-		        },
-		        "name": "%s-%s-edit"
-		    },
-		    "roleRef": {
-		        "apiGroup": "rbac.authorization.k8s.io",
-		        "kind": "ClusterRole",
-		        "name": "crossplane:provider:%s:aggregate-to-edit"
-		    },
-		    "subjects": [
-		        {
-		            "kind": "ServiceAccount",
-		            "name": "%s",
-		            "namespace": "%s"
-		        }
-		    ]
-		}
-		`)
-		ocrb := &v1alpha2.Object{
-			ObjectMeta: metav1.ObjectMeta{
-				Annotations: map[string]string{
-					"crossplane.io/external-name": fmt.Sprintf("%s-%s-edit", tenantName, pr.GetLabels()["pkg.crossplane.io/package"]),
-				},
-			},
-			Spec: v1alpha2.ObjectSpec{
-				ForProvider: v1alpha2.ObjectParameters{
-					Manifest: runtime.RawExtension{
-						Raw: []byte(fmt.Sprintf(
-							string(manifestFmt),
-							tenantName,
-							pr.GetLabels()["pkg.crossplane.io/package"],
-							pr.GetName(),
-							tenantName,
-							tenantName,
-						)),
-					},
-				},
-			},
+		f.log.Info("Label pkg.crossplane.io/package", "providerName", b.Revision.Package)
+		f.log.Info("ProviderRevision Name", "providerRevisionName", b.Revision.GetName())
+		f.log.Info("Bound ClusterRole", "role", b.Role.Name, "clusterRoleName", b.ClusterRole)
+
+		if b.Rules != nil {
+			ocr, err := compose(in, b.ClusterRole, b.Name, clusterRole(b.ClusterRole, in.Labels, b.Rules))
+			if err != nil {
+				response.Fatal(rsp, errors.Wrapf(err, "cannot compose ClusterRole %q", b.ClusterRole))
+				return rsp, nil
+			}
+			desired[resource.Name(b.ClusterRoleResourceName)] = &resource.DesiredComposed{Resource: ocr}
 		}
 
-		// Convert the object to the unstructured resource data format the SDK
-		// uses to store desired composed resources.
-		unsocrb, err := composed.From(ocrb)
+		unsocrb, err := compose(in, b.Name, b.Name, clusterRoleBinding(b.Name, in.Labels, b.ClusterRole, b.Subjects))
 		if err != nil {
-			response.Fatal(rsp, errors.Wrapf(err, "cannot convert %T to %T", unsocrb, &composed.Unstructured{}))
+			response.Fatal(rsp, errors.Wrapf(err, "cannot compose ClusterRoleBinding %q", b.Name))
 			return rsp, nil
 		}
 
@@ -137,8 +245,66 @@ func (f *Function) RunFunction(ctx context.Context, req *fnv1.RunFunctionRequest
 		// resource.Name every time it's called. The function prefixes the name
 		// with "xbuckets-" to avoid collisions with any other composed
 		// resources that might be in the desired resources map.
-		desired[resource.Name(fmt.Sprintf("%s-%s-edit", tenantName, pr.GetLabels()["pkg.crossplane.io/package"]))] = &resource.DesiredComposed{Resource: unsocrb}
+		desired[resource.Name(b.Name)] = &resource.DesiredComposed{Resource: unsocrb}
+	}
+
+	observed, err := request.GetObservedComposedResources(req)
+	if err != nil {
+		response.Fatal(rsp, errors.Wrapf(err, "cannot get observed composed resources from %T", req))
+		return rsp, nil
 	}
+	released := []string{}
+	if in.ReleasedBindingsFieldPath != "" {
+		if released, err = xr.Resource.GetStringArray(in.ReleasedBindingsFieldPath); err != nil && !fieldpath.IsNotFound(err) {
+			response.Fatal(rsp, errors.Wrapf(err, "cannot get the released bindings at %s", in.ReleasedBindingsFieldPath))
+			return rsp, nil
+		}
+	}
+	if names := added(bindings, observed); len(names) > 0 {
+		response.Normalf(rsp, "Adding bindings: %s", strings.Join(names, ", ")).
+			WithReason(reasonBindingsAdded).
+			TargetCompositeAndClaim()
+	}
+	pruned := prune(in, observed, desired, released)
+	if len(pruned.Removed) > 0 {
+		response.Warning(rsp, errors.Errorf("removing bindings that are no longer desired: %s", strings.Join(pruned.Removed, ", "))).
+			WithReason(reasonBindingsRemoved).
+			TargetCompositeAndClaim()
+	}
+	if len(pruned.Retained) > 0 {
+		response.Normalf(rsp, "Retaining bindings that are no longer desired until they are released: %s", strings.Join(pruned.Retained, ", ")).
+			WithReason(reasonBindingsRetained).
+			TargetCompositeAndClaim()
+	}
+
+	// Mark the composed resources ready explicitly, so that the XR is only
+	// ready once its bindings are applied.
+	setReadiness(desired, observed)
+
+	statuses := bindingStatuses(bindings, observed)
+	if in.StatusFieldPath != "" {
+		dxr, err := request.GetDesiredCompositeResource(req)
+		if err != nil {
+			response.Fatal(rsp, errors.Wrapf(err, "cannot get desired composite resource from %T", req))
+			return rsp, nil
+		}
+		if err := dxr.Resource.SetValue(in.StatusFieldPath, statuses); err != nil {
+			response.Fatal(rsp, errors.Wrapf(err, "cannot write the bindings to the composite resource status at %s", in.StatusFieldPath))
+			return rsp, nil
+		}
+		if err := response.SetDesiredCompositeResource(rsp, dxr); err != nil {
+			response.Fatal(rsp, errors.Wrapf(err, "cannot set desired composite resource in %T", rsp))
+			return rsp, nil
+		}
+	}
+
+	// Tell later steps of the pipeline which providers the tenant got.
+	bc, err := newBindingsContext(tenantName, statuses)
+	if err != nil {
+		response.Fatal(rsp, errors.Wrap(err, "cannot publish the bindings to the pipeline context"))
+		return rsp, nil
+	}
+	response.SetContextKey(rsp, contextKeyBindings, bc)
 
 	// Finally, save the updated desired composed resources to the response.
 	if err := response.SetDesiredComposedResources(rsp, desired); err != nil {
@@ -157,33 +323,124 @@ func (f *Function) RunFunction(ctx context.Context, req *fnv1.RunFunctionRequest
 	// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#typical-status-properties
 	response.ConditionTrue(rsp, "FunctionSuccess", "Success").
 		TargetCompositeAndClaim()
+	response.ConditionTrue(rsp, conditionDiscovered, reasonDiscovered).
+		TargetCompositeAndClaim()
+
+	switch failing, pending := unreadyBindings(bindings, observed); {
+	case len(failing) > 0:
+		response.ConditionFalse(rsp, conditionBindingsReady, reasonBindingsFailing).
+			WithMessage("Cannot apply bindings: " + strings.Join(failing, ", ")).
+			TargetCompositeAndClaim()
+	case len(pending) > 0:
+		response.ConditionFalse(rsp, conditionBindingsReady, reasonBindingsPending).
+			WithMessage("Waiting for bindings to become ready: " + strings.Join(pending, ", ")).
+			TargetCompositeAndClaim()
+	default:
+		response.ConditionTrue(rsp, conditionBindingsReady, reasonBindingsReady).
+			TargetCompositeAndClaim()
+	}
 
 	return rsp, nil
 }
 
-// fetchProviderRevisions is the default implementation for fetching ProviderRevisions.
-func fetchProviderRevisions(ctx context.Context, log logging.Logger) (*unstructured.UnstructuredList, error) {
-	config, err := rest.InClusterConfig()
+// keepObserved keeps the bindings the function composed earlier, and their
+// summary on the composite resource status, unchanged when the
+// ProviderRevisions cannot be discovered, and publishes them to the pipeline
+// context. It explains the supplied discovery error in a Warning result and a
+// condition.
+func keepObserved(req *fnv1.RunFunctionRequest, rsp *fnv1.RunFunctionResponse, in *v1beta1.Input, err error) *fnv1.RunFunctionResponse {
+	desired, derr := request.GetDesiredComposedResources(req)
+	if derr != nil {
+		response.Fatal(rsp, errors.Wrapf(derr, "cannot get desired resources from %T", req))
+		return rsp
+	}
+	observed, oerr := request.GetObservedComposedResources(req)
+	if oerr != nil {
+		response.Fatal(rsp, errors.Wrapf(oerr, "cannot get observed composed resources from %T", req))
+		return rsp
+	}
+	for name, oc := range observed {
+		if _, ok := oc.Resource.GetLabels()[labelBinding]; !ok {
+			continue
+		}
+		if _, ok := desired[name]; !ok {
+			desired[name] = &resource.DesiredComposed{Resource: retain(oc.Resource)}
+		}
+	}
+	setReadiness(desired, observed)
+	if serr := response.SetDesiredComposedResources(rsp, desired); serr != nil {
+		response.Fatal(rsp, errors.Wrapf(serr, "cannot set desired composed resources in %T", rsp))
+		return rsp
+	}
+	if in.StatusFieldPath != "" {
+		// Crossplane applies the status with server-side apply, which would
+		// remove a summary the function does not write again.
+		if serr := keepStatus(req, rsp, in.StatusFieldPath); serr != nil {
+			response.Fatal(rsp, serr)
+			return rsp
+		}
+	}
+
+	// Later steps of the pipeline still get the bindings that are kept.
+	if cerr := keepContext(req, rsp, in, observed); cerr != nil {
+		response.Fatal(rsp, errors.Wrap(cerr, "cannot publish the bindings to the pipeline context"))
+		return rsp
+	}
+
+	response.Warning(rsp, errors.Wrap(err, "cannot discover ProviderRevisions, keeping the bindings composed earlier"))
+	response.ConditionFalse(rsp, conditionDiscovered, reasonDiscoveryFailed).
+		WithMessage(err.Error()).
+		TargetCompositeAndClaim()
+	return rsp
+}
+
+// keepStatus copies the summary of the bindings at the supplied field path of
+// the observed composite resource status to the desired composite resource.
+func keepStatus(req *fnv1.RunFunctionRequest, rsp *fnv1.RunFunctionResponse, fieldPath string) error {
+	xr, err := request.GetObservedCompositeResource(req)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get the composite resource from %T", req)
+	}
+	summary, err := xr.Resource.GetValue(fieldPath)
+	if fieldpath.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot get the bindings of the composite resource status at %s", fieldPath)
+	}
+	dxr, err := request.GetDesiredCompositeResource(req)
 	if err != nil {
-		log.Info("Failed to get in-cluster config", "error", err)
-		return nil, err
+		return errors.Wrapf(err, "cannot get desired composite resource from %T", req)
 	}
-	dynamicClient, err := dynamic.NewForConfig(config)
+	if err := dxr.Resource.SetValue(fieldPath, summary); err != nil {
+		return errors.Wrapf(err, "cannot write the bindings to the composite resource status at %s", fieldPath)
+	}
+	return errors.Wrapf(response.SetDesiredCompositeResource(rsp, dxr), "cannot set desired composite resource in %T", rsp)
+}
+
+// keepContext publishes the bindings kept from the supplied observed composed
+// resources to the pipeline context. They are described per the summary of
+// the observed composite resource status when there is one, or else by their
+// name and readiness only.
+func keepContext(req *fnv1.RunFunctionRequest, rsp *fnv1.RunFunctionResponse, in *v1beta1.Input, observed map[resource.Name]resource.ObservedComposed) error {
+	xr, err := request.GetObservedCompositeResource(req)
 	if err != nil {
-		log.Info("Failed to create dynamic client", "error", err)
-		return nil, err
+		return errors.Wrapf(err, "cannot get the composite resource from %T", req)
 	}
-	// Define GVR for ProviderRevisions
-	gvr := schema.GroupVersionResource{
-		Group:    "pkg.crossplane.io",
-		Version:  "v1",
-		Resource: "providerrevisions",
+	tenantName, err := xr.Resource.GetString(in.TenantFieldPath)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get the XR tenant name from %T", req)
+	}
+	summary := []bindingStatus{}
+	if in.StatusFieldPath != "" {
+		if err := xr.Resource.GetValueInto(in.StatusFieldPath, &summary); err != nil && !fieldpath.IsNotFound(err) {
+			return errors.Wrapf(err, "cannot get the bindings of the composite resource status at %s", in.StatusFieldPath)
+		}
 	}
-	// List ProviderRevisions
-	providerRevisions, err := dynamicClient.Resource(gvr).Namespace("").List(ctx, metav1.ListOptions{})
+	bc, err := newBindingsContext(tenantName, keptBindingStatuses(summary, observed))
 	if err != nil {
-		log.Info("Failed to list ProviderRevisions", "error", err)
-		return nil, err
+		return err
 	}
-	return providerRevisions, nil
+	response.SetContextKey(rsp, contextKeyBindings, bc)
+	return nil
 }
@@ -3,6 +3,9 @@ package main
 import (
 	// Standard library imports
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -12,14 +15,16 @@ import (
 	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
 
 	// Imports with the prefix github.com/crossplane
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/function-sdk-go/errors"
 	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
 	"github.com/crossplane/function-sdk-go/resource"
 )
 
-// Define a TestFunction that embeds Function and overrides fetchProviderRevisions
+// Define a TestFunction that embeds Function and overrides its RevisionSource
 type TestFunction struct {
 	Function
 }
@@ -45,7 +50,7 @@ func TestRunFunction(t *testing.T) {
 						"finalizers": []interface{}{
 							"revision.pkg.crossplane.io",
 						},
-						"generation": 2,
+						"generation": int64(2),
 						"labels": map[string]interface{}{
 							"pkg.crossplane.io/package": "provider-kubernetes",
 						},
@@ -68,7 +73,7 @@ func TestRunFunction(t *testing.T) {
 						"ignoreCrossplaneConstraints": false,
 						"image":                       "xpkg.upbound.io/upbound/provider-kubernetes:v0.16.0",
 						"packagePullPolicy":           "IfNotPresent",
-						"revision":                    1,
+						"revision":                    int64(1),
 						"runtimeConfigRef": map[string]interface{}{
 							"apiVersion": "pkg.crossplane.io/v1beta1",
 							"kind":       "DeploymentRuntimeConfig",
@@ -133,7 +138,7 @@ func TestRunFunction(t *testing.T) {
 						"finalizers": []interface{}{
 							"revision.pkg.crossplane.io",
 						},
-						"generation": 1,
+						"generation": int64(1),
 						"labels": map[string]interface{}{
 							"pkg.crossplane.io/package":         "provider-family-azure",
 							"pkg.crossplane.io/provider-family": "provider-family-azure",
@@ -157,7 +162,7 @@ func TestRunFunction(t *testing.T) {
 						"ignoreCrossplaneConstraints": false,
 						"image":                       "xpkg.upbound.io/upbound/provider-family-azure:v1.10.0",
 						"packagePullPolicy":           "IfNotPresent",
-						"revision":                    1,
+						"revision":                    int64(1),
 						"runtimeConfigRef": map[string]interface{}{
 							"apiVersion": "pkg.crossplane.io/v1beta1",
 							"kind":       "DeploymentRuntimeConfig",
@@ -217,6 +222,35 @@ func TestRunFunction(t *testing.T) {
 					},
 				},
 			},
+			{
+				// An Inactive revision left behind by an upgrade of
+				// provider-kubernetes. It must not be bound.
+				Object: map[string]interface{}{
+					"apiVersion": "pkg.crossplane.io/v1",
+					"kind":       "ProviderRevision",
+					"metadata": map[string]interface{}{
+						"labels": map[string]interface{}{
+							"pkg.crossplane.io/package": "provider-kubernetes",
+						},
+						"name": "provider-kubernetes-2b5e4f2c1a0d",
+					},
+					"spec": map[string]interface{}{
+						"desiredState": "Inactive",
+						"image":        "xpkg.upbound.io/upbound/provider-kubernetes:v0.15.0",
+						"revision":     int64(0),
+					},
+					"status": map[string]interface{}{
+						"conditions": []interface{}{
+							map[string]interface{}{
+								"lastTransitionTime": "2024-12-12T18:44:50Z",
+								"reason":             "HealthyPackageRevision",
+								"status":             "True",
+								"type":               "Healthy",
+							},
+						},
+					},
+				},
+			},
 		},
 	}
 
@@ -228,6 +262,9 @@ func TestRunFunction(t *testing.T) {
 				"metadata": {
 					"annotations": {
 						"crossplane.io/external-name": "demo000-provider-kubernetes-edit"
+					},
+					"labels": {
+						"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-edit"
 					}
 				},
 				"spec": {
@@ -262,6 +299,7 @@ func TestRunFunction(t *testing.T) {
 					"observedGeneration": 0
 				}
 			}`),
+			Ready: fnv1.Ready_READY_FALSE,
 		},
 		"demo000-provider-family-azure-edit": {
 			Resource: resource.MustStructJSON(`{
@@ -270,6 +308,9 @@ func TestRunFunction(t *testing.T) {
 				"metadata": {
 					"annotations": {
 						"crossplane.io/external-name": "demo000-provider-family-azure-edit"
+					},
+					"labels": {
+						"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-family-azure-edit"
 					}
 				},
 				"spec": {
@@ -304,18 +345,72 @@ func TestRunFunction(t *testing.T) {
 					"observedGeneration": 0
 				}
 			}`),
+			Ready: fnv1.Ready_READY_FALSE,
+		},
+	}
+
+	requireProviderRevisions := &fnv1.Requirements{
+		ExtraResources: map[string]*fnv1.ResourceSelector{
+			"providerRevisions": {
+				ApiVersion: "pkg.crossplane.io/v1",
+				Kind:       "ProviderRevision",
+				Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{}},
+			},
 		},
 	}
 
+	excludedFamilyAzure := &fnv1.Result{
+		Severity: fnv1.Severity_SEVERITY_WARNING,
+		Message:  "providers excluded by the provider selector: provider-family-azure (not selected)",
+		Reason:   ptr.To("ProvidersExcluded"),
+		Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+	}
+
 	type args struct {
-		ctx context.Context
-		req *fnv1.RunFunctionRequest
+		ctx    context.Context
+		req    *fnv1.RunFunctionRequest
+		source RevisionSource
 	}
 	type want struct {
 		rsp *fnv1.RunFunctionResponse
 		err error
 	}
 
+	revisionsFile := filepath.Join(t.TempDir(), "provider-revisions.yaml")
+	if err := os.WriteFile(revisionsFile, []byte(`# A YAML stream of ProviderRevisions, like those of a cluster.
+apiVersion: pkg.crossplane.io/v1
+kind: ProviderRevision
+metadata:
+  name: provider-kubernetes-71953a1e5c15
+  labels:
+    pkg.crossplane.io/package: provider-kubernetes
+spec:
+  desiredState: Active
+  revision: 1
+status:
+  conditions:
+  - type: Healthy
+    status: "True"
+    reason: HealthyPackageRevision
+    lastTransitionTime: "2024-12-12T19:03:42Z"
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	malformedRevisionsFile := filepath.Join(t.TempDir(), "provider-revisions.yaml")
+	if err := os.WriteFile(malformedRevisionsFile, []byte(`apiVersion: pkg.crossplane.io/v1
+kind: Provider
+metadata:
+  name: provider-kubernetes
+---
+apiVersion: pkg.crossplane.io/v1
+kind: ProviderRevision
+metadata:
+  labels:
+    pkg.crossplane.io/package: provider-kubernetes
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
 	cases := map[string]struct {
 		reason string
 		args   args
@@ -325,6 +420,9 @@ func TestRunFunction(t *testing.T) {
 			reason: "The Function should return two new ClusterRoleBindings for the observed XFluxcdTenant. Given there are two ProviderRevisions in the mock response.",
 			args: args{
 				req: &fnv1.RunFunctionRequest{
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
 					Observed: &fnv1.State{
 						Composite: &fnv1.Resource{
 							Resource: resource.MustStructJSON(`{
@@ -345,6 +443,46 @@ func TestRunFunction(t *testing.T) {
 			want: want{
 				rsp: &fnv1.RunFunctionResponse{
 					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-family-azure-edit",
+									"package": "provider-family-azure",
+									"ready": false,
+									"revision": "provider-family-azure-7e0a66cff496",
+									"role": "edit"
+								},
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-family-azure",
+									"revision": "provider-family-azure-7e0a66cff496"
+								},
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
 					Conditions: []*fnv1.Condition{
 						{
 							Type:   "FunctionSuccess",
@@ -352,6 +490,19 @@ func TestRunFunction(t *testing.T) {
 							Reason: "Success",
 							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
 						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
 					},
 					Desired: &fnv1.State{
 						Resources: expectedDesiredComposed,
@@ -360,36 +511,3375 @@ func TestRunFunction(t *testing.T) {
 				err: nil,
 			},
 		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			testFunc := &TestFunction{
-				Function: Function{
-					log: logging.NewNopLogger(),
-					fetchProviderRevisionsFunc: func(_ context.Context, _ logging.Logger) (*unstructured.UnstructuredList, error) {
-						return mockProviderRevisions, nil
+		"MissingNamespaces": {
+			reason: "The Function should bind the tenant ServiceAccount in the tenant namespace when the XR has no field at the namespaces field path.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"namespacesFieldPath": "spec.namespaces"
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "gitAuthProvider": "azure",
+							        "gitBranch": "main",
+							        "gitPath": "/demo000",
+							        "gitUrl": "https://dev.azure.com/Someorg/prj-idp2/_git/repo-idp2",
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
 					},
 				},
-			}
-			rsp, err := testFunc.RunFunction(tc.args.ctx, tc.args.req)
-
-			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
-				t.Errorf("%s\nf.RunFunction(...): -want err, +got err:\n%s", tc.reason, diff)
-			}
-
-			// Compare the response meta and conditions
-			if diff := cmp.Diff(tc.want.rsp.GetMeta(), rsp.GetMeta(), protocmp.Transform()); diff != "" {
-				t.Errorf("%s\nf.RunFunction(...): -want meta, +got meta:\n%s", tc.reason, diff)
-			}
-			if diff := cmp.Diff(tc.want.rsp.GetConditions(), rsp.GetConditions(), protocmp.Transform()); diff != "" {
-				t.Errorf("%s\nf.RunFunction(...): -want conditions, +got conditions:\n%s", tc.reason, diff)
-			}
-
-			// Compare the desired composed resources
-			if diff := cmp.Diff(tc.want.rsp.GetDesired().GetResources(), rsp.GetDesired().GetResources(), protocmp.Transform()); diff != "" {
-				t.Errorf("%s\nf.RunFunction(...): -want desired composed, +got desired composed:\n%s", tc.reason, diff)
-			}
-		})
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-family-azure-edit",
+									"package": "provider-family-azure",
+									"ready": false,
+									"revision": "provider-family-azure-7e0a66cff496",
+									"role": "edit"
+								},
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-family-azure",
+									"revision": "provider-family-azure-7e0a66cff496"
+								},
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: expectedDesiredComposed,
+					},
+				},
+				err: nil,
+			},
+		},
+		"EmptyNamespaces": {
+			reason: "The Function should bind the tenant ServiceAccount in the tenant namespace when the XR has an empty list at the namespaces field path.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"namespacesFieldPath": "spec.namespaces"
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "gitAuthProvider": "azure",
+							        "gitBranch": "main",
+							        "gitPath": "/demo000",
+							        "gitUrl": "https://dev.azure.com/Someorg/prj-idp2/_git/repo-idp2",
+							        "namespaces": [],
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-family-azure-edit",
+									"package": "provider-family-azure",
+									"ready": false,
+									"revision": "provider-family-azure-7e0a66cff496",
+									"role": "edit"
+								},
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-family-azure",
+									"revision": "provider-family-azure-7e0a66cff496"
+								},
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: expectedDesiredComposed,
+					},
+				},
+				err: nil,
+			},
+		},
+		"CustomInput": {
+			reason: "The Function should honor the tenant field path, roles, labels, name template and provider selector of its input.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"tenantFieldPath": "spec.parameters.tenant",
+						"roles": [
+							{
+								"flavor": "View"
+							}
+						],
+						"labels": {
+							"team": "platform"
+						},
+						"nameTemplate": "{{ .Package }}-{{ .TenantName }}-{{ .Role }}",
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							}
+						}
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "parameters": {
+							            "tenant": "demo001"
+							        }
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "provider-kubernetes-demo001-view",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "view"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo001"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						excludedFamilyAzure,
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: provider-kubernetes-demo001-view",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: provider-kubernetes-demo001-view"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"provider-kubernetes-demo001-view": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "provider-kubernetes-demo001-view"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "provider-kubernetes-demo001-view"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"team": "platform"
+													},
+													"name": "provider-kubernetes-demo001-view"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-view"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "demo001",
+														"namespace": "demo001"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"InvalidInput": {
+			reason: "The Function should return a fatal result when its input is invalid.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"nameTemplate": "{{ .TenantName"
+					}`),
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Message:  "invalid Function input: nameTemplate: Invalid value: \"{{ .TenantName\": template: name:1: unclosed action",
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"RequireProviderRevisions": {
+			reason: "The Function should require ProviderRevisions as extra resources when Crossplane did not supply them.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta:         &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Requirements: requireProviderRevisions,
+				},
+				err: nil,
+			},
+		},
+		"RequireServerSideSelectedProviderRevisions": {
+			reason: "The Function should only require the ProviderRevisions a server-side provider selector selects.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							},
+							"serverSide": true
+						}
+					}`),
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Requirements: &fnv1.Requirements{
+						ExtraResources: map[string]*fnv1.ResourceSelector{
+							"providerRevisions": {
+								ApiVersion: "pkg.crossplane.io/v1",
+								Kind:       "ProviderRevision",
+								Match: &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{
+									Labels: map[string]string{"pkg.crossplane.io/package": "provider-kubernetes"},
+								}},
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"InputRevisionsFile": {
+			reason: "The Function should bind the ProviderRevisions of the input revisionsFile without asking Crossplane for any.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(fmt.Sprintf(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"revisionsFile": %q
+					}`, revisionsFile)),
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": expectedDesiredComposed["demo000-provider-kubernetes-edit"],
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"InputRevisionsFileEmptyExtraResources": {
+			reason: "The Function should bind the ProviderRevisions of the input revisionsFile rather than the empty list of ProviderRevisions crossplane render supplies as extra resources.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(fmt.Sprintf(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"revisionsFile": %q
+					}`, revisionsFile)),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": {},
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": expectedDesiredComposed["demo000-provider-kubernetes-edit"],
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"MalformedInputRevisionsFile": {
+			reason: "The Function should return a fatal result naming the malformed document of the input revisionsFile.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(fmt.Sprintf(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"revisionsFile": %q
+					}`, malformedRevisionsFile)),
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Message:  fmt.Sprintf("cannot read the ProviderRevisions of the input revisionsFile: cannot decode ProviderRevisions from %s: document 2 is a ProviderRevision without a metadata.name", malformedRevisionsFile),
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"DiscoveryFailureFatal": {
+			reason: "The Function should return a fatal result when the ProviderRevisions cannot be discovered.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+				source: &fakeSource{Err: errors.New("the server is currently unable to handle the request")},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Message:  "cannot discover ProviderRevisions: the server is currently unable to handle the request",
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"DiscoveryFailureKeepObserved": {
+			reason: "The Function should keep the bindings it composed earlier, and explain why, when the ProviderRevisions cannot be discovered.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"onDiscoveryFailure": "KeepObserved"
+					}`),
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-x7k2p",
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-edit"
+										},
+										"resourceVersion": "42"
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding"
+											}
+										}
+									},
+									"status": {
+										"conditions": []
+									}
+								}`),
+							},
+							"ftnamespace": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-a1b2c"
+									}
+								}`),
+							},
+						},
+					},
+				},
+				source: &fakeSource{Err: errors.New("the server is currently unable to handle the request")},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"ready": false
+								}
+							],
+							"providers": [],
+							"tenantName": "demo000"
+						}
+					}`),
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_WARNING,
+							Message:  "cannot discover ProviderRevisions, keeping the bindings composed earlier: the server is currently unable to handle the request",
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:    "ProviderRevisionsDiscovered",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "DiscoveryFailed",
+							Message: ptr.To("the server is currently unable to handle the request"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-x7k2p",
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-edit"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding"
+											}
+										}
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"DiscoveryFailureKeepStatus": {
+			reason: "The Function should keep the summary of the bindings it composed earlier on the composite status when the ProviderRevisions cannot be discovered.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"onDiscoveryFailure": "KeepObserved",
+						"statusFieldPath": "status.providerBindings"
+					}`),
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    },
+							    "status": {
+							        "providerBindings": [
+							            {
+							                "binding": "demo000-provider-kubernetes-edit",
+							                "package": "provider-kubernetes",
+							                "ready": true,
+							                "revision": "provider-kubernetes-71953a1e5c15",
+							                "role": "edit"
+							            }
+							        ]
+							    }
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-x7k2p",
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-edit"
+										},
+										"resourceVersion": "42"
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding"
+											}
+										}
+									},
+									"status": {
+										"conditions": []
+									}
+								}`),
+							},
+							"ftnamespace": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-a1b2c"
+									}
+								}`),
+							},
+						},
+					},
+					Desired: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "status": {
+							        "namespace": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+				source: &fakeSource{Err: errors.New("the server is currently unable to handle the request")},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": true,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_WARNING,
+							Message:  "cannot discover ProviderRevisions, keeping the bindings composed earlier: the server is currently unable to handle the request",
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:    "ProviderRevisionsDiscovered",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "DiscoveryFailed",
+							Message: ptr.To("the server is currently unable to handle the request"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "status": {
+							        "namespace": "demo000",
+							        "providerBindings": [
+							            {
+							                "binding": "demo000-provider-kubernetes-edit",
+							                "package": "provider-kubernetes",
+							                "ready": true,
+							                "revision": "provider-kubernetes-71953a1e5c15",
+							                "role": "edit"
+							            }
+							        ]
+							    }
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-x7k2p",
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-edit"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding"
+											}
+										}
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"StatusSummary": {
+			reason: "The Function should summarize the bindings on the desired composite status, preserving the status of earlier steps.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"statusFieldPath": "status.providerBindings"
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-x7k2p",
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-edit"
+										}
+									},
+									"status": {
+										"conditions": [
+											{
+												"type": "Ready",
+												"status": "True",
+												"reason": "Available",
+												"lastTransitionTime": "2024-12-12T19:03:42Z"
+											}
+										]
+									}
+								}`),
+							},
+						},
+					},
+					Desired: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "status": {
+							        "namespace": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-family-azure-edit",
+									"package": "provider-family-azure",
+									"ready": false,
+									"revision": "provider-family-azure-7e0a66cff496",
+									"role": "edit"
+								},
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": true,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-family-azure",
+									"revision": "provider-family-azure-7e0a66cff496"
+								},
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-family-azure-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-family-azure-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "status": {
+							        "namespace": "demo000",
+							        "providerBindings": [
+							            {
+							                "package": "provider-family-azure",
+							                "revision": "provider-family-azure-7e0a66cff496",
+							                "role": "edit",
+							                "binding": "demo000-provider-family-azure-edit",
+							                "ready": false
+							            },
+							            {
+							                "package": "provider-kubernetes",
+							                "revision": "provider-kubernetes-71953a1e5c15",
+							                "role": "edit",
+							                "binding": "demo000-provider-kubernetes-edit",
+							                "ready": true
+							            }
+							        ]
+							    }
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": {
+								Resource: expectedDesiredComposed["demo000-provider-kubernetes-edit"].GetResource(),
+								Ready:    fnv1.Ready_READY_TRUE,
+							},
+							"demo000-provider-family-azure-edit": expectedDesiredComposed["demo000-provider-family-azure-edit"],
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"BindingsFailing": {
+			reason: "The Function should mark each composed resource ready per its observed conditions, and name the bindings that cannot be applied.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input"
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-x7k2p",
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-edit"
+										}
+									},
+									"status": {
+										"conditions": [
+											{
+												"type": "Synced",
+												"status": "False",
+												"reason": "ReconcileError",
+												"message": "cannot create object: clusterrolebindings is forbidden",
+												"lastTransitionTime": "2024-12-12T19:03:42Z"
+											}
+										]
+									}
+								}`),
+							},
+							"demo000-provider-family-azure-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-q9m4z",
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-family-azure-edit"
+										}
+									},
+									"status": {
+										"conditions": [
+											{
+												"type": "Synced",
+												"status": "True",
+												"reason": "ReconcileSuccess",
+												"lastTransitionTime": "2024-12-12T19:03:42Z"
+											},
+											{
+												"type": "Ready",
+												"status": "True",
+												"reason": "Available",
+												"lastTransitionTime": "2024-12-12T19:03:42Z"
+											}
+										]
+									}
+								}`),
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-family-azure-edit",
+									"package": "provider-family-azure",
+									"ready": true,
+									"revision": "provider-family-azure-7e0a66cff496",
+									"role": "edit"
+								},
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-family-azure",
+									"revision": "provider-family-azure-7e0a66cff496"
+								},
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Failing",
+							Message: ptr.To("Cannot apply bindings: demo000-provider-kubernetes-edit (cannot create object: clusterrolebindings is forbidden)"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": expectedDesiredComposed["demo000-provider-kubernetes-edit"],
+							"demo000-provider-family-azure-edit": {
+								Resource: expectedDesiredComposed["demo000-provider-family-azure-edit"].GetResource(),
+								Ready:    fnv1.Ready_READY_TRUE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"ConfiguredRevisionSource": {
+			reason: "The Function should use the ProviderRevisions of a configured source, even when Crossplane supplied none as extra resources, and not ask Crossplane for them.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": {},
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+				source: &fakeSource{List: mockProviderRevisions},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-family-azure-edit",
+									"package": "provider-family-azure",
+									"ready": false,
+									"revision": "provider-family-azure-7e0a66cff496",
+									"role": "edit"
+								},
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-family-azure",
+									"revision": "provider-family-azure-7e0a66cff496"
+								},
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: expectedDesiredComposed,
+					},
+				},
+				err: nil,
+			},
+		},
+		"UnhealthyFamilyConfigProvider": {
+			reason: "The Function should warn about the family config provider of a bound provider family member that cannot be bound itself.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": {
+							Items: []*fnv1.Resource{
+								{Resource: resource.MustStructJSON(`{
+									"apiVersion": "pkg.crossplane.io/v1",
+									"kind": "ProviderRevision",
+									"metadata": {
+										"name": "provider-azure-network-9b2c4d6e8f01",
+										"labels": {
+											"pkg.crossplane.io/package": "provider-azure-network",
+											"pkg.crossplane.io/provider-family": "provider-family-azure"
+										}
+									},
+									"spec": {"desiredState": "Active", "revision": 1},
+									"status": {"conditions": [{"type": "Healthy", "status": "True", "reason": "HealthyPackageRevision"}]}
+								}`)},
+								{Resource: resource.MustStructJSON(`{
+									"apiVersion": "pkg.crossplane.io/v1",
+									"kind": "ProviderRevision",
+									"metadata": {
+										"name": "provider-family-azure-7e0a66cff496",
+										"labels": {
+											"pkg.crossplane.io/package": "provider-family-azure",
+											"pkg.crossplane.io/provider-family": "provider-family-azure"
+										}
+									},
+									"spec": {"desiredState": "Active", "revision": 1},
+									"status": {"conditions": [{"type": "Healthy", "status": "False", "reason": "UnhealthyPackageRevision"}]}
+								}`)},
+							},
+						},
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-azure-network-edit",
+									"package": "provider-azure-network",
+									"ready": false,
+									"revision": "provider-azure-network-9b2c4d6e8f01",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-azure-network",
+									"revision": "provider-azure-network-9b2c4d6e8f01"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_WARNING,
+							Message:  "family config providers of bound provider family members have no healthy revision to bind: provider-family-azure",
+							Reason:   ptr.To("FamilyConfigProvidersUnbound"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-azure-network-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-azure-network-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-azure-network-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "demo000-provider-azure-network-edit"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-azure-network-edit"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "demo000-provider-azure-network-edit"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-azure-network-9b2c4d6e8f01:aggregate-to-edit"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "demo000",
+														"namespace": "demo000"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"InvalidTenantName": {
+			reason: "The Function should return a fatal result when the tenant name is not a valid namespace name, rather than render a broken manifest.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo\\\"000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta:         &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Message:  `invalid XR tenant name at spec.tenantName: tenant name "demo\\\"000" is used as a namespace and ServiceAccount name: a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`,
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"ShortenLongNames": {
+			reason: "The Function should shorten generated names longer than the maximum name length consistently.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"maxNameLength": 20,
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							}
+						}
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-pro-63f1332c",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						excludedFamilyAzure,
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-pro-63f1332c",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-pro-63f1332c"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-pro-63f1332c": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "demo000-pro-63f1332c"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-pro-63f1332c"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "demo000-pro-63f1332c"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-edit"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "demo000",
+														"namespace": "demo000"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"NameCollision": {
+			reason: "The Function should return a fatal result when the name template generates the same name for two providers.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"nameTemplate": "{{ .TenantName }}-edit"
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta:         &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Message:  `the edit binding of ProviderRevision "provider-family-azure-7e0a66cff496" and the edit binding of ProviderRevision "provider-kubernetes-71953a1e5c15" are both named "demo000-edit": the name template must generate a distinct name per provider and role`,
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"InvalidNameTemplate": {
+			reason: "The Function should return a fatal result when the name template generates a name the API server would reject.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"nameTemplate": "{{ .TenantName }}_{{ .Package }}"
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta:         &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Message:  `nameTemplate "{{ .TenantName }}_{{ .Package }}" generates the invalid name "demo000_provider-family-azure" for the edit binding of ProviderRevision "provider-family-azure-7e0a66cff496": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"InvalidCustomClusterRole": {
+			reason: "The Function should return a fatal result when the ClusterRole template of a Custom role generates a name the API server would reject.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"roles": [
+							{
+								"flavor": "Custom",
+								"name": "usage",
+								"clusterRole": "{{ .Package }}/usage"
+							}
+						]
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta:         &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Message:  `clusterRole "{{ .Package }}/usage" of role usage generates the invalid name "provider-family-azure/usage" for ProviderRevision "provider-family-azure-7e0a66cff496": may not contain '/'`,
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"MultipleRoles": {
+			reason: "The Function should generate a binding per provider and role, named after the role.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"roles": [
+							{
+								"flavor": "View"
+							},
+							{
+								"flavor": "Custom",
+								"name": "usage",
+								"clusterRole": "system:{{ .Package }}:Provider_Config_Usage"
+							}
+						],
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							}
+						}
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-kubernetes-view",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "view"
+								},
+								{
+									"binding": "demo000-provider-kubernetes-usage",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "usage"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						excludedFamilyAzure,
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-kubernetes-view, demo000-provider-kubernetes-usage",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-kubernetes-view, demo000-provider-kubernetes-usage"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-view": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "demo000-provider-kubernetes-view"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-view"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "demo000-provider-kubernetes-view"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-view"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "demo000",
+														"namespace": "demo000"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+							"demo000-provider-kubernetes-usage": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "demo000-provider-kubernetes-usage"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-usage"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "demo000-provider-kubernetes-usage"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "system:provider-kubernetes:Provider_Config_Usage"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "demo000",
+														"namespace": "demo000"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"ProviderConfigUsageRole": {
+			reason: "The Function should generate a ClusterRole granting access only to the ProviderConfigUsages of each provider, and bind it.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"roles": [
+							{
+								"flavor": "ProviderConfigUsage",
+								"name": "usage",
+								"verbs": ["get", "create"]
+							}
+						],
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							}
+						}
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-kubernetes-usage",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "usage"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						excludedFamilyAzure,
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-kubernetes-usage",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-kubernetes-usage"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-usage-clusterrole": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "demo000-provider-kubernetes-usage"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-usage"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRole",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "demo000-provider-kubernetes-usage"
+												},
+												"rules": [
+													{
+														"apiGroups": ["kubernetes.crossplane.io"],
+														"resources": ["providerconfigusages"],
+														"verbs": ["get", "create"]
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+							"demo000-provider-kubernetes-usage": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "demo000-provider-kubernetes-usage"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-usage"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "demo000-provider-kubernetes-usage"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "demo000-provider-kubernetes-usage"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "demo000",
+														"namespace": "demo000"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"DirectOutput": {
+			reason: "The Function should compose ClusterRoleBindings directly rather than wrapped in a provider-kubernetes Object when requested.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"output": "Direct",
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							}
+						}
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						excludedFamilyAzure,
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "rbac.authorization.k8s.io/v1",
+									"kind": "ClusterRoleBinding",
+									"metadata": {
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-edit",
+											"kustomize.toolkit.fluxcd.io/name": "tenants",
+											"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+										},
+										"name": "demo000-provider-kubernetes-edit"
+									},
+									"roleRef": {
+										"apiGroup": "rbac.authorization.k8s.io",
+										"kind": "ClusterRole",
+										"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-edit"
+									},
+									"subjects": [
+										{
+											"kind": "ServiceAccount",
+											"name": "demo000",
+											"namespace": "demo000"
+										}
+									]
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"ObjectOptions": {
+			reason: "The Function should apply the Object options of its input to every generated Object.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"object": {
+							"providerConfigRef": {
+								"name": "in-cluster"
+							},
+							"managementPolicies": ["Observe", "Create", "Update", "LateInitialize"],
+							"deletionPolicy": "Orphan",
+							"readiness": {
+								"policy": "DeriveFromObject"
+							},
+							"watch": true
+						},
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							}
+						}
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						excludedFamilyAzure,
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "demo000-provider-kubernetes-edit"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-kubernetes-edit"
+										}
+									},
+									"spec": {
+										"providerConfigRef": {
+											"name": "in-cluster"
+										},
+										"managementPolicies": ["Observe", "Create", "Update", "LateInitialize"],
+										"deletionPolicy": "Orphan",
+										"readiness": {
+											"policy": "DeriveFromObject"
+										},
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "demo000-provider-kubernetes-edit"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-edit"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "demo000",
+														"namespace": "demo000"
+													}
+												]
+											}
+										},
+										"watch": true
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"ObjectOptionsWithDirectOutput": {
+			reason: "The Function should return a fatal result when Object options are supplied in Direct output mode.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"output": "Direct",
+						"object": {
+							"deletionPolicy": "Orphan"
+						}
+					}`),
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Message:  "invalid Function input: object: Forbidden: only supported by the Object output mode",
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"MultipleSubjects": {
+			reason: "The Function should bind the tenant ServiceAccount of every namespace and every additional subject of the XR.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"namespacesFieldPath": "spec.namespaces",
+						"subjectsFieldPath": "spec.subjects",
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							}
+						}
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "dev-team",
+							        "namespaces": ["frontend", "backend"],
+							        "subjects": [
+							            {
+							                "kind": "Group",
+							                "name": "platform-admins"
+							            }
+							        ]
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "dev-team-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "dev-team"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						excludedFamilyAzure,
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: dev-team-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: dev-team-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"dev-team-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "dev-team-provider-kubernetes-edit"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "dev-team-provider-kubernetes-edit"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "dev-team-provider-kubernetes-edit"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-edit"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "dev-team",
+														"namespace": "frontend"
+													},
+													{
+														"kind": "ServiceAccount",
+														"name": "dev-team",
+														"namespace": "backend"
+													},
+													{
+														"apiGroup": "rbac.authorization.k8s.io",
+														"kind": "Group",
+														"name": "platform-admins"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"TenantProviders": {
+			reason: "The Function should only bind the providers the tenant is entitled to, and warn about entitlements no installed provider matches.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"providersFieldPath": "spec.providers"
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "dev-team",
+							        "providers": [
+							            "provider-kubernetes",
+							            {
+							                "matchLabels": {
+							                    "pkg.crossplane.io/package": "provider-helm"
+							                }
+							            }
+							        ]
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "dev-team-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "dev-team"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_WARNING,
+							Message:  "providers requested by the tenant are not installed: pkg.crossplane.io/package=provider-helm",
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: dev-team-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: dev-team-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"dev-team-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "dev-team-provider-kubernetes-edit"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "dev-team-provider-kubernetes-edit"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "dev-team-provider-kubernetes-edit"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-edit"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "dev-team",
+														"namespace": "dev-team"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"ContextProviders": {
+			reason: "The Function should read the providers the tenant is entitled to from the pipeline context when the XR lists none, and publish its bindings to the context.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"providersFieldPath": "spec.providers",
+						"providersContextKey": "example.org/tenant-providers"
+					}`),
+					Context: resource.MustStructJSON(`{
+						"example.org/tenant-providers": [
+							"provider-kubernetes",
+							{
+								"matchLabels": {
+									"pkg.crossplane.io/package": "provider-helm"
+								}
+							}
+						]
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "dev-team"
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"example.org/tenant-providers": [
+							"provider-kubernetes",
+							{
+								"matchLabels": {
+									"pkg.crossplane.io/package": "provider-helm"
+								}
+							}
+						],
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "dev-team-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "dev-team"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_WARNING,
+							Message:  "providers requested by the tenant are not installed: pkg.crossplane.io/package=provider-helm",
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: dev-team-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: dev-team-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"dev-team-provider-kubernetes-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "dev-team-provider-kubernetes-edit"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "dev-team-provider-kubernetes-edit"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "dev-team-provider-kubernetes-edit"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-edit"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "dev-team",
+														"namespace": "dev-team"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"BindingPerSubject": {
+			reason: "The Function should generate a binding per subject, suffixed with the subject, when requested.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"namespacesFieldPath": "spec.namespaces",
+						"subjectsFieldPath": "spec.subjects",
+						"bindingPerSubject": true,
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							}
+						}
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "dev-team",
+							        "namespaces": ["frontend", "backend"]
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "dev-team-provider-kubernetes-edit-frontend-dev-team",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								},
+								{
+									"binding": "dev-team-provider-kubernetes-edit-backend-dev-team",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "dev-team"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						excludedFamilyAzure,
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: dev-team-provider-kubernetes-edit-frontend-dev-team, dev-team-provider-kubernetes-edit-backend-dev-team",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: dev-team-provider-kubernetes-edit-frontend-dev-team, dev-team-provider-kubernetes-edit-backend-dev-team"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: map[string]*fnv1.Resource{
+							"dev-team-provider-kubernetes-edit-frontend-dev-team": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "dev-team-provider-kubernetes-edit-frontend-dev-team"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "dev-team-provider-kubernetes-edit-frontend-dev-team"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "dev-team-provider-kubernetes-edit-frontend-dev-team"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-edit"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "dev-team",
+														"namespace": "frontend"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+							"dev-team-provider-kubernetes-edit-backend-dev-team": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"annotations": {
+											"crossplane.io/external-name": "dev-team-provider-kubernetes-edit-backend-dev-team"
+										},
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "dev-team-provider-kubernetes-edit-backend-dev-team"
+										}
+									},
+									"spec": {
+										"forProvider": {
+											"manifest": {
+												"apiVersion": "rbac.authorization.k8s.io/v1",
+												"kind": "ClusterRoleBinding",
+												"metadata": {
+													"labels": {
+														"kustomize.toolkit.fluxcd.io/name": "tenants",
+														"kustomize.toolkit.fluxcd.io/namespace": "flux-system"
+													},
+													"name": "dev-team-provider-kubernetes-edit-backend-dev-team"
+												},
+												"roleRef": {
+													"apiGroup": "rbac.authorization.k8s.io",
+													"kind": "ClusterRole",
+													"name": "crossplane:provider:provider-kubernetes-71953a1e5c15:aggregate-to-edit"
+												},
+												"subjects": [
+													{
+														"kind": "ServiceAccount",
+														"name": "dev-team",
+														"namespace": "backend"
+													}
+												]
+											}
+										},
+										"watch": false
+									},
+									"status": {
+										"observedGeneration": 0
+									}
+								}`),
+								Ready: fnv1.Ready_READY_FALSE,
+							},
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"InvalidSubject": {
+			reason: "The Function should return a fatal result when a subject of the XR cannot be bound.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					Input: resource.MustStructJSON(`{
+						"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+						"kind": "Input",
+						"namespacesFieldPath": "spec.namespaces",
+						"subjectsFieldPath": "spec.subjects",
+						"providerSelector": {
+							"matchLabels": {
+								"pkg.crossplane.io/package": "provider-kubernetes"
+							}
+						}
+					}`),
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "dev-team",
+							        "namespaces": ["Frontend"]
+							    }
+							}`),
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta:         &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_FATAL,
+							Message:  `cannot get the subjects of the tenant: namespace "Frontend" of ServiceAccount "dev-team" is not a valid namespace name: a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`,
+							Target:   fnv1.Target_TARGET_COMPOSITE.Enum(),
+						},
+					},
+				},
+				err: nil,
+			},
+		},
+		"PruneUninstalledProvider": {
+			reason: "The Function should report the removal of bindings it composed earlier for providers that are no longer installed.",
+			args: args{
+				req: &fnv1.RunFunctionRequest{
+					ExtraResources: map[string]*fnv1.Resources{
+						"providerRevisions": mustResources(mockProviderRevisions),
+					},
+					Observed: &fnv1.State{
+						Composite: &fnv1.Resource{
+							Resource: resource.MustStructJSON(`{
+							    "apiVersion": "gitops.idp.someorg.com/v1alpha1",
+							    "kind": "XFluxcdTenant",
+							    "spec": {
+							        "tenantName": "demo000"
+							    }
+							}`),
+						},
+						Resources: map[string]*fnv1.Resource{
+							"demo000-provider-helm-edit": {
+								Resource: resource.MustStructJSON(`{
+									"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+									"kind": "Object",
+									"metadata": {
+										"name": "demo000-x7k2p",
+										"labels": {
+											"fluxcdtenantcrbs.fn.crossplane.io/binding": "demo000-provider-helm-edit"
+										}
+									}
+								}`),
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				rsp: &fnv1.RunFunctionResponse{
+					Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(60 * time.Second)},
+					Context: resource.MustStructJSON(`{
+						"apiextensions.crossplane.io/fluxcd-tenant-crbs": {
+							"bindings": [
+								{
+									"binding": "demo000-provider-family-azure-edit",
+									"package": "provider-family-azure",
+									"ready": false,
+									"revision": "provider-family-azure-7e0a66cff496",
+									"role": "edit"
+								},
+								{
+									"binding": "demo000-provider-kubernetes-edit",
+									"package": "provider-kubernetes",
+									"ready": false,
+									"revision": "provider-kubernetes-71953a1e5c15",
+									"role": "edit"
+								}
+							],
+							"providers": [
+								{
+									"package": "provider-family-azure",
+									"revision": "provider-family-azure-7e0a66cff496"
+								},
+								{
+									"package": "provider-kubernetes",
+									"revision": "provider-kubernetes-71953a1e5c15"
+								}
+							],
+							"tenantName": "demo000"
+						}
+					}`),
+					Requirements: requireProviderRevisions,
+					Results: []*fnv1.Result{
+						{
+							Severity: fnv1.Severity_SEVERITY_NORMAL,
+							Message:  "Adding bindings: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit",
+							Reason:   ptr.To("BindingsAdded"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Severity: fnv1.Severity_SEVERITY_WARNING,
+							Message:  "removing bindings that are no longer desired: demo000-provider-helm-edit",
+							Reason:   ptr.To("BindingsRemoved"),
+							Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Conditions: []*fnv1.Condition{
+						{
+							Type:   "FunctionSuccess",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Success",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:   "ProviderRevisionsDiscovered",
+							Status: fnv1.Status_STATUS_CONDITION_TRUE,
+							Reason: "Discovered",
+							Target: fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+						{
+							Type:    "BindingsReady",
+							Status:  fnv1.Status_STATUS_CONDITION_FALSE,
+							Reason:  "Creating",
+							Message: ptr.To("Waiting for bindings to become ready: demo000-provider-family-azure-edit, demo000-provider-kubernetes-edit"),
+							Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+						},
+					},
+					Desired: &fnv1.State{
+						Resources: expectedDesiredComposed,
+					},
+				},
+				err: nil,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			testFunc := &TestFunction{
+				Function: Function{
+					log:       logging.NewNopLogger(),
+					revisions: tc.args.source,
+				},
+			}
+			rsp, err := testFunc.RunFunction(tc.args.ctx, tc.args.req)
+
+			if diff := cmp.Diff(tc.want.err, err, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want err, +got err:\n%s", tc.reason, diff)
+			}
+
+			// Compare the response meta and conditions
+			if diff := cmp.Diff(tc.want.rsp.GetMeta(), rsp.GetMeta(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want meta, +got meta:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.rsp.GetConditions(), rsp.GetConditions(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want conditions, +got conditions:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.rsp.GetResults(), rsp.GetResults(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want results, +got results:\n%s", tc.reason, diff)
+			}
+
+			if diff := cmp.Diff(tc.want.rsp.GetRequirements(), rsp.GetRequirements(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want requirements, +got requirements:\n%s", tc.reason, diff)
+			}
+
+			// Compare the pipeline context
+			if diff := cmp.Diff(tc.want.rsp.GetContext(), rsp.GetContext(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want context, +got context:\n%s", tc.reason, diff)
+			}
+
+			// Compare the desired composite and composed resources
+			if diff := cmp.Diff(tc.want.rsp.GetDesired().GetComposite(), rsp.GetDesired().GetComposite(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired composite, +got desired composite:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.rsp.GetDesired().GetResources(), rsp.GetDesired().GetResources(), protocmp.Transform()); diff != "" {
+				t.Errorf("%s\nf.RunFunction(...): -want desired composed, +got desired composed:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// mustResources converts the supplied ProviderRevisions to the extra resources
+// format of a RunFunctionRequest.
+func mustResources(l *unstructured.UnstructuredList) *fnv1.Resources {
+	rs := &fnv1.Resources{}
+	for i := range l.Items {
+		s, err := resource.AsStruct(&l.Items[i])
+		if err != nil {
+			panic(err)
+		}
+		rs.Items = append(rs.Items, &fnv1.Resource{Resource: s})
 	}
+	return rs
 }
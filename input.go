@@ -0,0 +1,278 @@
+package main
+
+import (
+	// Standard library imports
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+
+	// Default imports (third-party packages not matching other prefixes)
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	// Imports with prefix github.com/crossplane
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/function-sdk-go/errors"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/request"
+
+	// Imports with prefix github.com/crossplane-contrib
+	"github.com/crossplane-contrib/provider-kubernetes/apis/object/v1alpha2"
+
+	"github.com/chelala/function-fluxcd-tenant-crossplane-providers-usage-resource-crbs/input/v1beta1"
+)
+
+// minNameLength is the shortest MaxNameLength that leaves room for at least
+// one character before the hash of a shortened name.
+const minNameLength = nameHashLength + 2
+
+// getInput returns the validated Function input of the supplied request, with
+// defaults applied to every omitted field. A request without input yields the
+// default input.
+func getInput(req *fnv1.RunFunctionRequest) (*v1beta1.Input, error) {
+	in := &v1beta1.Input{}
+	if req.GetInput() != nil {
+		if err := request.GetInput(req, in); err != nil {
+			return nil, errors.Wrap(err, "cannot get Function input")
+		}
+	}
+	setInputDefaults(in)
+	if errs := validateInput(in); len(errs) > 0 {
+		return nil, errors.Wrap(errs.ToAggregate(), "invalid Function input")
+	}
+	return in, nil
+}
+
+// setInputDefaults sets the default value of every omitted input field.
+func setInputDefaults(in *v1beta1.Input) {
+	if in.TenantFieldPath == "" {
+		in.TenantFieldPath = v1beta1.DefaultTenantFieldPath
+	}
+	if len(in.Roles) == 0 {
+		in.Roles = []v1beta1.Role{{}}
+	}
+	for i := range in.Roles {
+		r := &in.Roles[i]
+		if r.Flavor == "" {
+			r.Flavor = v1beta1.RoleFlavorEdit
+		}
+		if r.Name == "" {
+			r.Name = strings.ToLower(string(r.Flavor))
+		}
+		if r.Flavor == v1beta1.RoleFlavorProviderConfigUsage && len(r.Verbs) == 0 {
+			r.Verbs = v1beta1.DefaultProviderConfigUsageVerbs()
+		}
+	}
+	if in.Labels == nil {
+		in.Labels = v1beta1.DefaultLabels()
+	}
+	if in.NameTemplate == "" {
+		in.NameTemplate = v1beta1.DefaultNameTemplate
+	}
+	if in.Output == "" {
+		in.Output = v1beta1.OutputModeObject
+	}
+	if in.PrunePolicy == "" {
+		in.PrunePolicy = v1beta1.PrunePolicyDelete
+	}
+	if in.OnDiscoveryFailure == "" {
+		in.OnDiscoveryFailure = v1beta1.DiscoveryFailureFatal
+	}
+	if in.MaxNameLength == 0 {
+		in.MaxNameLength = v1beta1.DefaultMaxNameLength
+	}
+}
+
+// validateInput validates a defaulted input.
+func validateInput(in *v1beta1.Input) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if _, err := fieldpath.Parse(in.TenantFieldPath); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("tenantFieldPath"), in.TenantFieldPath, err.Error()))
+	}
+	if _, err := fieldpath.Parse(in.NamespacesFieldPath); in.NamespacesFieldPath != "" && err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("namespacesFieldPath"), in.NamespacesFieldPath, err.Error()))
+	}
+	if _, err := fieldpath.Parse(in.SubjectsFieldPath); in.SubjectsFieldPath != "" && err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("subjectsFieldPath"), in.SubjectsFieldPath, err.Error()))
+	}
+	if _, err := fieldpath.Parse(in.ProvidersFieldPath); in.ProvidersFieldPath != "" && err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("providersFieldPath"), in.ProvidersFieldPath, err.Error()))
+	}
+	if in.StatusFieldPath != "" {
+		if s, err := fieldpath.Parse(in.StatusFieldPath); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("statusFieldPath"), in.StatusFieldPath, err.Error()))
+		} else if len(s) < 2 || s[0].Field != "status" {
+			errs = append(errs, field.Invalid(field.NewPath("statusFieldPath"), in.StatusFieldPath, "must be a field of the status"))
+		}
+	}
+	errs = append(errs, validateRoles(in.Roles, field.NewPath("roles"))...)
+	errs = append(errs, validateLabels(in.Labels, field.NewPath("labels"))...)
+	if _, err := template.New("name").Parse(in.NameTemplate); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("nameTemplate"), in.NameTemplate, err.Error()))
+	}
+	if in.MaxNameLength < minNameLength || in.MaxNameLength > validation.DNS1123SubdomainMaxLength {
+		errs = append(errs, field.Invalid(field.NewPath("maxNameLength"), in.MaxNameLength, fmt.Sprintf("must be between %d and %d", minNameLength, validation.DNS1123SubdomainMaxLength)))
+	}
+	switch in.Output {
+	case v1beta1.OutputModeObject, v1beta1.OutputModeDirect:
+	default:
+		errs = append(errs, field.NotSupported(field.NewPath("output"), in.Output, []string{string(v1beta1.OutputModeObject), string(v1beta1.OutputModeDirect)}))
+	}
+	switch in.PrunePolicy {
+	case v1beta1.PrunePolicyDelete, v1beta1.PrunePolicyRetain:
+	default:
+		errs = append(errs, field.NotSupported(field.NewPath("prunePolicy"), in.PrunePolicy, []string{string(v1beta1.PrunePolicyDelete), string(v1beta1.PrunePolicyRetain)}))
+	}
+	if _, err := fieldpath.Parse(in.ReleasedBindingsFieldPath); in.ReleasedBindingsFieldPath != "" && err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("releasedBindingsFieldPath"), in.ReleasedBindingsFieldPath, err.Error()))
+	}
+	switch in.OnDiscoveryFailure {
+	case v1beta1.DiscoveryFailureFatal, v1beta1.DiscoveryFailureKeepObserved:
+	default:
+		errs = append(errs, field.NotSupported(field.NewPath("onDiscoveryFailure"), in.OnDiscoveryFailure, []string{string(v1beta1.DiscoveryFailureFatal), string(v1beta1.DiscoveryFailureKeepObserved)}))
+	}
+	if in.Object != nil {
+		errs = append(errs, validateObjectOptions(in.Object, in.Output, field.NewPath("object"))...)
+	}
+	if in.ProviderSelector != nil {
+		errs = append(errs, validateProviderSelector(in.ProviderSelector, field.NewPath("providerSelector"))...)
+	}
+
+	return errs
+}
+
+// validateRoles validates defaulted roles.
+func validateRoles(roles []v1beta1.Role, p *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	names := map[string]bool{}
+	for i, r := range roles {
+		rp := p.Index(i)
+		switch r.Flavor {
+		case v1beta1.RoleFlavorEdit, v1beta1.RoleFlavorView, v1beta1.RoleFlavorProviderConfigUsage:
+			if r.ClusterRole != "" {
+				errs = append(errs, field.Invalid(rp.Child("clusterRole"), r.ClusterRole, fmt.Sprintf("only supported by the %s flavor", v1beta1.RoleFlavorCustom)))
+			}
+		case v1beta1.RoleFlavorCustom:
+			if r.ClusterRole == "" {
+				errs = append(errs, field.Required(rp.Child("clusterRole"), fmt.Sprintf("required by the %s flavor", v1beta1.RoleFlavorCustom)))
+			}
+			if _, err := template.New("clusterRole").Parse(r.ClusterRole); err != nil {
+				errs = append(errs, field.Invalid(rp.Child("clusterRole"), r.ClusterRole, err.Error()))
+			}
+		default:
+			errs = append(errs, field.NotSupported(rp.Child("flavor"), r.Flavor, []string{string(v1beta1.RoleFlavorEdit), string(v1beta1.RoleFlavorView), string(v1beta1.RoleFlavorCustom), string(v1beta1.RoleFlavorProviderConfigUsage)}))
+		}
+		if len(r.Verbs) > 0 && r.Flavor != v1beta1.RoleFlavorProviderConfigUsage {
+			errs = append(errs, field.Invalid(rp.Child("verbs"), r.Verbs, fmt.Sprintf("only supported by the %s flavor", v1beta1.RoleFlavorProviderConfigUsage)))
+		}
+		for _, msg := range validation.IsDNS1123Label(r.Name) {
+			errs = append(errs, field.Invalid(rp.Child("name"), r.Name, msg))
+		}
+		if names[r.Name] {
+			errs = append(errs, field.Duplicate(rp.Child("name"), r.Name))
+		}
+		names[r.Name] = true
+	}
+	return errs
+}
+
+// validateObjectOptions validates the options of generated Objects.
+func validateObjectOptions(o *v1beta1.ObjectOptions, mode v1beta1.OutputMode, p *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if mode != v1beta1.OutputModeObject {
+		errs = append(errs, field.Forbidden(p, fmt.Sprintf("only supported by the %s output mode", v1beta1.OutputModeObject)))
+	}
+	if o.ProviderConfigRef != nil && o.ProviderConfigRef.Name == "" {
+		errs = append(errs, field.Required(p.Child("providerConfigRef", "name"), ""))
+	}
+	for i, a := range o.ManagementPolicies {
+		switch xpv1.ManagementAction(a) {
+		case xpv1.ManagementActionObserve, xpv1.ManagementActionCreate, xpv1.ManagementActionUpdate, xpv1.ManagementActionDelete, xpv1.ManagementActionLateInitialize, xpv1.ManagementActionAll:
+		default:
+			errs = append(errs, field.NotSupported(p.Child("managementPolicies").Index(i), a, []string{"Observe", "Create", "Update", "Delete", "LateInitialize", "*"}))
+		}
+	}
+	switch xpv1.DeletionPolicy(o.DeletionPolicy) {
+	case "", xpv1.DeletionOrphan, xpv1.DeletionDelete:
+	default:
+		errs = append(errs, field.NotSupported(p.Child("deletionPolicy"), o.DeletionPolicy, []string{string(xpv1.DeletionOrphan), string(xpv1.DeletionDelete)}))
+	}
+	if r := o.Readiness; r != nil {
+		switch v1alpha2.ReadinessPolicy(r.Policy) {
+		case v1alpha2.ReadinessPolicySuccessfulCreate, v1alpha2.ReadinessPolicyDeriveFromObject, v1alpha2.ReadinessPolicyAllTrue:
+		case v1alpha2.ReadinessPolicyDeriveFromCelQuery:
+			if r.CelQuery == "" {
+				errs = append(errs, field.Required(p.Child("readiness", "celQuery"), fmt.Sprintf("required by the %s policy", r.Policy)))
+			}
+		default:
+			errs = append(errs, field.NotSupported(p.Child("readiness", "policy"), r.Policy, []string{string(v1alpha2.ReadinessPolicySuccessfulCreate), string(v1alpha2.ReadinessPolicyDeriveFromObject), string(v1alpha2.ReadinessPolicyAllTrue), string(v1alpha2.ReadinessPolicyDeriveFromCelQuery)}))
+		}
+	}
+	return errs
+}
+
+// validateProviderSelector validates a provider selector.
+func validateProviderSelector(ps *v1beta1.ProviderSelector, p *field.Path) field.ErrorList {
+	errs := validateLabels(ps.MatchLabels, p.Child("matchLabels"))
+	if _, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchExpressions: ps.MatchExpressions}); err != nil {
+		errs = append(errs, field.Invalid(p.Child("matchExpressions"), ps.MatchExpressions, err.Error()))
+	}
+	for i, g := range ps.Packages {
+		if _, err := path.Match(g, ""); err != nil {
+			errs = append(errs, field.Invalid(p.Child("packages").Index(i), g, err.Error()))
+		}
+	}
+	for i, re := range ps.PackagePatterns {
+		if _, err := regexp.Compile(re); err != nil {
+			errs = append(errs, field.Invalid(p.Child("packagePatterns").Index(i), re, err.Error()))
+		}
+	}
+	for i, g := range ps.Deny {
+		if _, err := path.Match(g, ""); err != nil {
+			errs = append(errs, field.Invalid(p.Child("deny").Index(i), g, err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateLabels validates the keys and values of the supplied labels.
+func validateLabels(l map[string]string, p *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for k, v := range l {
+		for _, msg := range validation.IsQualifiedName(k) {
+			errs = append(errs, field.Invalid(p, k, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(v) {
+			errs = append(errs, field.Invalid(p.Key(k), v, msg))
+		}
+	}
+	return errs
+}
+
+// templateData is the data the input NameTemplate and the ClusterRole of
+// Custom roles are executed with.
+type templateData struct {
+	TenantName string
+	Package    string
+	Revision   string
+	Role       string
+}
+
+// executeTemplate executes the supplied input template.
+func executeTemplate(tmpl string, d templateData) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse template")
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, d); err != nil {
+		return "", errors.Wrap(err, "cannot execute template")
+	}
+	return buf.String(), nil
+}
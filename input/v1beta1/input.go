@@ -0,0 +1,375 @@
+// Package v1beta1 contains the input type for this Function
+// +kubebuilder:object:generate=true
+// +groupName=fluxcdtenantcrbs.fn.crossplane.io
+// +versionName=v1beta1
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// This isn't a custom resource, in the sense that we never install its CRD.
+// It is a KRM-like object, so we generate a CRD to describe its schema.
+
+// Default values used when the corresponding Input field is omitted. They
+// reproduce the behaviour of the function before it accepted any input.
+const (
+	DefaultTenantFieldPath = "spec.tenantName"
+	DefaultNameTemplate    = "{{ .TenantName }}-{{ .Package }}-{{ .Role }}"
+	DefaultMaxNameLength   = 63
+)
+
+// DefaultLabels returns the labels applied to every generated
+// ClusterRoleBinding when Labels is omitted. They match the labels set by
+// `flux create tenant`, so the bindings are owned by the tenants
+// Kustomization.
+func DefaultLabels() map[string]string {
+	return map[string]string{
+		"kustomize.toolkit.fluxcd.io/name":      "tenants",
+		"kustomize.toolkit.fluxcd.io/namespace": "flux-system",
+	}
+}
+
+// Input configures how the Function generates the ClusterRoleBindings of a
+// FluxCD tenant.
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:resource:categories=crossplane
+type Input struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// TenantFieldPath is the field path of the observed composite resource
+	// that holds the FluxCD tenant name. The tenant name is used as the name
+	// and the namespace of the tenant ServiceAccount.
+	// +kubebuilder:default="spec.tenantName"
+	// +optional
+	TenantFieldPath string `json:"tenantFieldPath,omitempty"`
+
+	// NamespacesFieldPath is the field path of the observed composite
+	// resource that holds the list of namespaces the tenant ServiceAccount
+	// exists in, like the namespaces of
+	// `flux create tenant --with-namespace`. The ServiceAccount of every
+	// namespace is bound. Only the namespace named after the tenant is bound
+	// when omitted, or when the XR holds no namespaces there.
+	// +optional
+	NamespacesFieldPath string `json:"namespacesFieldPath,omitempty"`
+
+	// SubjectsFieldPath is the field path of the observed composite resource
+	// that holds a list of additional RBAC subjects to bind. Each subject has
+	// a kind (ServiceAccount, User or Group), a name and, for
+	// ServiceAccounts, a namespace.
+	// +optional
+	SubjectsFieldPath string `json:"subjectsFieldPath,omitempty"`
+
+	// BindingPerSubject generates a binding per subject, provider and role,
+	// suffixed with the subject, instead of a binding of all subjects per
+	// provider and role.
+	// +optional
+	BindingPerSubject bool `json:"bindingPerSubject,omitempty"`
+
+	// ProvidersFieldPath is the field path of the observed composite resource
+	// that holds the providers the tenant is entitled to. Each entry is either
+	// a package name, which may be a glob, or an object with a package and
+	// matchLabels selecting ProviderRevisions. Only the selected providers
+	// the tenant is entitled to are bound. A Warning is emitted for every
+	// entitlement no installed provider matches. Every selected provider is
+	// bound when omitted, or when the composite resource has no such field.
+	// +optional
+	ProvidersFieldPath string `json:"providersFieldPath,omitempty"`
+
+	// ProvidersContextKey is the key of the pipeline context that holds the
+	// providers the tenant is entitled to, written by an earlier step of the
+	// pipeline, in the format of ProvidersFieldPath. It is only read when the
+	// composite resource holds no providers at ProvidersFieldPath.
+	// +optional
+	ProvidersContextKey string `json:"providersContextKey,omitempty"`
+
+	// Roles bound to the tenant for every selected provider. Each role
+	// generates its own binding per provider. Defaults to a single Edit role.
+	// +optional
+	Roles []Role `json:"roles,omitempty"`
+
+	// Labels applied to every generated ClusterRoleBinding. Defaults to the
+	// labels of the FluxCD tenants Kustomization. Set to an empty object to
+	// generate ClusterRoleBindings without labels.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// NameTemplate is a Go template used to name the generated
+	// ClusterRoleBindings. The template can reference .TenantName, .Package,
+	// .Revision and .Role, the name of the bound role. It must reference
+	// .Role when more than one role is bound.
+	// +kubebuilder:default="{{ .TenantName }}-{{ .Package }}-{{ .Role }}"
+	// +optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
+
+	// MaxNameLength is the maximum length of a generated name. Longer names
+	// are truncated and suffixed with a short hash of the full name. The
+	// limit applies to the ClusterRoleBinding name, its external name and the
+	// composed resource name.
+	// +kubebuilder:default=63
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:validation:Maximum=253
+	// +optional
+	MaxNameLength int `json:"maxNameLength,omitempty"`
+
+	// Output determines how the generated RBAC resources are composed.
+	// Object wraps each of them in a provider-kubernetes Object, which
+	// requires provider-kubernetes and a ProviderConfig. Direct composes them
+	// as is, which requires Crossplane to be allowed to escalate and bind
+	// the bound ClusterRoles.
+	// +kubebuilder:default=Object
+	// +optional
+	Output OutputMode `json:"output,omitempty"`
+
+	// Object configures the provider-kubernetes Objects generated in Object
+	// output mode. It applies to every generated Object.
+	// +optional
+	Object *ObjectOptions `json:"object,omitempty"`
+
+	// IncludeInactiveRevisions also binds the tenant to healthy inactive
+	// ProviderRevisions, keeping access to a provider while it rolls over to
+	// a new revision. The binding of an inactive revision is suffixed with
+	// the revision hash. Only the active revision is bound by default.
+	// +optional
+	IncludeInactiveRevisions bool `json:"includeInactiveRevisions,omitempty"`
+
+	// ProviderSelector restricts the ProviderRevisions the tenant is bound
+	// to. All ProviderRevisions are selected when omitted.
+	// +optional
+	ProviderSelector *ProviderSelector `json:"providerSelector,omitempty"`
+
+	// PrunePolicy determines what happens to the bindings the function
+	// composed earlier that are no longer desired, like the bindings of an
+	// uninstalled provider. Delete removes them. Retain keeps them until they
+	// are released at ReleasedBindingsFieldPath. Removed bindings are
+	// reported in a Warning result, retained bindings in a Normal result.
+	// +optional
+	PrunePolicy PrunePolicy `json:"prunePolicy,omitempty"`
+
+	// ReleasedBindingsFieldPath is the field path of the observed composite
+	// resource that holds the names of the retained bindings to remove.
+	// +optional
+	ReleasedBindingsFieldPath string `json:"releasedBindingsFieldPath,omitempty"`
+
+	// RevisionsFile is the path, as seen by the function, of a YAML or JSON
+	// stream of ProviderRevisions, or of a directory of such files. When set
+	// the function binds these ProviderRevisions instead of those of the
+	// cluster, and ignores any Crossplane supplied as extra resources. It lets
+	// crossplane render preview the bindings of a tenant without a cluster.
+	// +optional
+	RevisionsFile string `json:"revisionsFile,omitempty"`
+
+	// OnDiscoveryFailure determines what happens when the ProviderRevisions
+	// cannot be discovered. Fatal fails the pipeline step. KeepObserved keeps
+	// the bindings the function composed earlier, and their summary at
+	// StatusFieldPath, unchanged, and emits a Warning and a
+	// ProviderRevisionsDiscovered condition explaining the failure.
+	// +optional
+	OnDiscoveryFailure DiscoveryFailurePolicy `json:"onDiscoveryFailure,omitempty"`
+
+	// StatusFieldPath is the field path of the desired composite resource
+	// status the function writes a summary of the bindings to: the package,
+	// revision, role, binding name and ready state of each binding. The
+	// status written by earlier pipeline steps is preserved. No summary is
+	// written when omitted. The path must start with status and be part of
+	// the composite resource schema.
+	// +optional
+	StatusFieldPath string `json:"statusFieldPath,omitempty"`
+}
+
+// A DiscoveryFailurePolicy determines what happens when the ProviderRevisions
+// cannot be discovered.
+// +kubebuilder:validation:Enum=Fatal;KeepObserved
+type DiscoveryFailurePolicy string
+
+// Discovery failure policies.
+const (
+	// DiscoveryFailureFatal fails the pipeline step.
+	DiscoveryFailureFatal DiscoveryFailurePolicy = "Fatal"
+
+	// DiscoveryFailureKeepObserved keeps the observed bindings unchanged.
+	DiscoveryFailureKeepObserved DiscoveryFailurePolicy = "KeepObserved"
+)
+
+// A PrunePolicy determines what happens to bindings that are no longer
+// desired.
+// +kubebuilder:validation:Enum=Delete;Retain
+type PrunePolicy string
+
+// Prune policies.
+const (
+	// PrunePolicyDelete removes bindings that are no longer desired.
+	PrunePolicyDelete PrunePolicy = "Delete"
+
+	// PrunePolicyRetain keeps bindings that are no longer desired until they
+	// are released.
+	PrunePolicyRetain PrunePolicy = "Retain"
+)
+
+// A ProviderSelector selects ProviderRevisions. A ProviderRevision is
+// selected when it matches every criteria that is set.
+type ProviderSelector struct {
+	// MatchLabels selects ProviderRevisions that have all of these labels.
+	// +optional
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+
+	// MatchExpressions selects ProviderRevisions whose labels satisfy all of
+	// these requirements.
+	// +optional
+	MatchExpressions []metav1.LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+
+	// Packages selects ProviderRevisions whose pkg.crossplane.io/package
+	// label matches any of these glob patterns, e.g. provider-azure-*. A
+	// ProviderRevision matching any of PackagePatterns is selected too.
+	// +optional
+	Packages []string `json:"packages,omitempty"`
+
+	// PackagePatterns selects ProviderRevisions whose
+	// pkg.crossplane.io/package label matches any of these regular
+	// expressions. A ProviderRevision matching any of Packages is selected
+	// too.
+	// +optional
+	PackagePatterns []string `json:"packagePatterns,omitempty"`
+
+	// Deny excludes ProviderRevisions whose pkg.crossplane.io/package label
+	// matches any of these glob patterns, even when they are otherwise
+	// selected.
+	// +optional
+	Deny []string `json:"deny,omitempty"`
+
+	// ServerSide pushes the provider selector to the server, so that only
+	// the selected ProviderRevisions are fetched in full. When the function
+	// lists them itself, MatchLabels and MatchExpressions are pushed to the
+	// API server, and only the names and labels of the other
+	// ProviderRevisions are fetched. When Crossplane supplies them as extra
+	// resources only MatchLabels is pushed: the ProviderRevisions those
+	// labels do not select are not supplied at all, and MatchExpressions
+	// are still applied by the function to every ProviderRevision supplied
+	// in full. A family config provider is then only bound when it is
+	// selected too.
+	// +optional
+	ServerSide bool `json:"serverSide,omitempty"`
+}
+
+// An OutputMode determines how generated RBAC resources are composed.
+// +kubebuilder:validation:Enum=Object;Direct
+type OutputMode string
+
+// Output modes.
+const (
+	// OutputModeObject composes every generated RBAC resource wrapped in a
+	// provider-kubernetes Object.
+	OutputModeObject OutputMode = "Object"
+
+	// OutputModeDirect composes every generated RBAC resource directly.
+	OutputModeDirect OutputMode = "Direct"
+)
+
+// ObjectOptions configure generated provider-kubernetes Objects. Omitted
+// options keep the provider-kubernetes defaults.
+type ObjectOptions struct {
+	// ProviderConfigRef is the provider-kubernetes ProviderConfig used to
+	// manage the Objects.
+	// +optional
+	ProviderConfigRef *ProviderConfigReference `json:"providerConfigRef,omitempty"`
+
+	// ManagementPolicies of the Objects.
+	// +optional
+	ManagementPolicies []ManagementAction `json:"managementPolicies,omitempty"`
+
+	// DeletionPolicy of the Objects. Orphan keeps the RBAC resources when
+	// their Object is deleted.
+	// +kubebuilder:validation:Enum=Orphan;Delete
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// Readiness determines when the Objects are considered ready.
+	// +optional
+	Readiness *ObjectReadiness `json:"readiness,omitempty"`
+
+	// Watch the RBAC resources managed by the Objects. Requires the
+	// provider-kubernetes watches feature.
+	// +optional
+	Watch bool `json:"watch,omitempty"`
+}
+
+// A ProviderConfigReference references a provider-kubernetes ProviderConfig.
+type ProviderConfigReference struct {
+	// Name of the ProviderConfig.
+	Name string `json:"name"`
+}
+
+// A ManagementAction the provider is allowed to take on a RBAC resource.
+// +kubebuilder:validation:Enum=Observe;Create;Update;Delete;LateInitialize;*
+type ManagementAction string
+
+// ObjectReadiness determines when an Object is considered ready.
+type ObjectReadiness struct {
+	// Policy used to determine readiness.
+	// +kubebuilder:validation:Enum=SuccessfulCreate;DeriveFromObject;AllTrue;DeriveFromCelQuery
+	Policy string `json:"policy"`
+
+	// CelQuery determining readiness. Required by the DeriveFromCelQuery
+	// policy.
+	// +optional
+	CelQuery string `json:"celQuery,omitempty"`
+}
+
+// A RoleFlavor determines the ClusterRole a Role binds.
+// +kubebuilder:validation:Enum=Edit;View;Custom;ProviderConfigUsage
+type RoleFlavor string
+
+// Role flavors.
+const (
+	// RoleFlavorEdit binds the aggregate-to-edit ClusterRole of the provider.
+	RoleFlavorEdit RoleFlavor = "Edit"
+
+	// RoleFlavorView binds the aggregate-to-view ClusterRole of the provider.
+	RoleFlavorView RoleFlavor = "View"
+
+	// RoleFlavorCustom binds the ClusterRole named by the role.
+	RoleFlavorCustom RoleFlavor = "Custom"
+
+	// RoleFlavorProviderConfigUsage generates and binds a ClusterRole that
+	// only grants access to the ProviderConfigUsage types of the provider.
+	RoleFlavorProviderConfigUsage RoleFlavor = "ProviderConfigUsage"
+)
+
+// DefaultProviderConfigUsageVerbs returns the verbs a ProviderConfigUsage
+// role grants when Verbs is omitted.
+func DefaultProviderConfigUsageVerbs() []string {
+	return []string{"get", "list", "watch", "create", "update", "patch"}
+}
+
+// A Role bound to the tenant for every selected provider.
+type Role struct {
+	// Flavor of the role. Edit and View bind the
+	// crossplane:provider:<provider-revision>:aggregate-to-<edit|view>
+	// ClusterRole Crossplane creates for every ProviderRevision. Custom binds
+	// the ClusterRole named by ClusterRole. ProviderConfigUsage generates a
+	// ClusterRole, named like its binding, that grants Verbs on the
+	// providerconfigusages types the ProviderRevision installs; providers
+	// without such a type get no binding for this role.
+	// +kubebuilder:default=Edit
+	// +optional
+	Flavor RoleFlavor `json:"flavor,omitempty"`
+
+	// ClusterRole bound by a Custom role. It is a Go template that can
+	// reference .TenantName, .Package and .Revision. Required for the Custom
+	// flavor.
+	// +optional
+	ClusterRole string `json:"clusterRole,omitempty"`
+
+	// Verbs granted on ProviderConfigUsages by a ProviderConfigUsage role.
+	// Defaults to get, list, watch, create, update and patch.
+	// +optional
+	Verbs []string `json:"verbs,omitempty"`
+
+	// Name of the role, available to NameTemplate as .Role so bindings of
+	// different roles coexist. Defaults to the lower case flavor, e.g. edit.
+	// Names must be unique.
+	// +optional
+	Name string `json:"name,omitempty"`
+}
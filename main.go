@@ -2,9 +2,14 @@
 package main
 
 import (
+	"context"
+	"time"
+
 	"github.com/alecthomas/kong"
 
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/crossplane/function-sdk-go"
+	"github.com/crossplane/function-sdk-go/errors"
 )
 
 // CLI of this Function.
@@ -16,6 +21,23 @@ type CLI struct {
 	TLSCertsDir        string `help:"Directory containing server certs (tls.key, tls.crt) and the CA used to verify client certificates (ca.crt)" env:"TLS_SERVER_CERTS_DIR"`
 	Insecure           bool   `help:"Run without mTLS credentials. If you supply this flag --tls-server-certs-dir will be ignored."`
 	MaxRecvMessageSize int    `help:"Maximum size of received messages in MB." default:"4"`
+
+	RevisionSource string `help:"Source of the ProviderRevisions: extra-resources (ask Crossplane for them), api (list them from the API server, which requires the function's service account to list ProviderRevisions) or file (read them from --revisions-file, which implies this source)." enum:"extra-resources,api,file" default:"extra-resources"`
+	RevisionsFile  string `help:"YAML or JSON stream of ProviderRevisions, or directory of such files, read by the file revision source. Lets crossplane render preview bindings without a cluster." type:"path"`
+
+	Kubeconfig  string        `help:"Kubeconfig file of the cluster discovery client. The in-cluster config is used unless this or --context is set." type:"path"`
+	Context     string        `help:"Kubeconfig context of the cluster discovery client. Loads $KUBECONFIG or ~/.kube/config unless --kubeconfig is set."`
+	KubeQPS     float32       `name:"kube-qps" help:"Maximum queries per second of the cluster discovery client." default:"20"`
+	KubeBurst   int           `name:"kube-burst" help:"Maximum burst of queries of the cluster discovery client." default:"30"`
+	KubeTimeout time.Duration `name:"kube-timeout" help:"Timeout of each request of the cluster discovery client." default:"10s"`
+
+	DiscoveryPageSize    int64         `help:"Number of ProviderRevisions listed per page by cluster discovery. 0 lists them all at once." default:"100"`
+	DiscoveryCache       string        `help:"Cache of the ProviderRevisions listed by cluster discovery: none, ttl (list again once older than --discovery-cache-ttl) or informer (watch ProviderRevisions)." enum:"none,ttl,informer" default:"none"`
+	DiscoveryCacheTTL    time.Duration `help:"How long the ttl discovery cache serves listed ProviderRevisions." default:"30s"`
+	DiscoveryCacheResync time.Duration `help:"Resync period of the informer discovery cache." default:"10m"`
+
+	MetricsAddress    string `help:"Address at which to serve Prometheus metrics at /metrics. Metrics are not served when empty."`
+	MetricsMaxTenants int    `help:"Maximum number of distinct tenants labelling metrics. Further tenants are labelled other." default:"100"`
 }
 
 // Run this Function.
@@ -25,13 +47,72 @@ func (c *CLI) Run() error {
 		return err
 	}
 
-	return function.Serve(&Function{log: log, fetchProviderRevisionsFunc: fetchProviderRevisions},
+	src, err := c.revisionSource(log)
+	if err != nil {
+		return err
+	}
+	fn := &Function{log: log, revisions: src}
+
+	if c.MetricsAddress != "" {
+		if err := serveMetrics(log, c.MetricsAddress); err != nil {
+			return err
+		}
+	}
+
+	return function.Serve(&instrumentedFunction{FunctionRunnerServiceServer: fn, tenants: newTenantLabeler(c.MetricsMaxTenants)},
 		function.Listen(c.Network, c.Address),
 		function.MTLSCertificates(c.TLSCertsDir),
 		function.Insecure(c.Insecure),
 		function.MaxRecvMessageSize(c.MaxRecvMessageSize*1024*1024))
 }
 
+// revisionSource returns the source of the ProviderRevisions selected by the
+// flags. It is the source of truth: ProviderRevisions Crossplane supplied as
+// extra resources are only used by the extra-resources source.
+func (c *CLI) revisionSource(log logging.Logger) (RevisionSource, error) {
+	source := c.RevisionSource
+	if c.RevisionsFile != "" && source == revisionSourceExtraResources {
+		source = revisionSourceFile
+	}
+
+	switch source {
+	case revisionSourceAPI:
+		config, err := getRESTConfig(c.Kubeconfig, c.Context)
+		if err != nil {
+			return nil, err
+		}
+		config.QPS = c.KubeQPS
+		config.Burst = c.KubeBurst
+		config.Timeout = c.KubeTimeout
+		l, err := newLister(config, c.DiscoveryPageSize)
+		if err != nil {
+			return nil, err
+		}
+		list := observeDiscovery(l.List)
+		api := &apiSource{log: log, fetch: list}
+		switch c.DiscoveryCache {
+		case discoveryCacheTTL:
+			api.fetch = newTTLCache(list, c.DiscoveryCacheTTL).Fetch
+		case discoveryCacheInformer:
+			ic := newInformerCache(l.client, c.DiscoveryCacheResync, list)
+			ic.Start(context.Background())
+			api.fetch = ic.Fetch
+		}
+		return api, nil
+	case revisionSourceFile:
+		if c.RevisionsFile == "" {
+			return nil, errors.New("--revisions-file is required by the file revision source")
+		}
+		fs, err := newFileSource(c.RevisionsFile)
+		if err != nil {
+			return nil, err
+		}
+		return fs, nil
+	default:
+		return extraResourcesSource{}, nil
+	}
+}
+
 func main() {
 	ctx := kong.Parse(&CLI{}, kong.Description("A Crossplane Composition Function."))
 	ctx.FatalIfErrorf(ctx.Run())
@@ -0,0 +1,193 @@
+package main
+
+import (
+	// Standard library imports
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/function-sdk-go/errors"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+)
+
+// Outcomes labelling metrics.
+const (
+	outcomeSuccess = "success"
+	outcomeWarning = "warning"
+	outcomeFatal   = "fatal"
+	outcomeError   = "error"
+)
+
+// Tenants labelling metrics when the tenant is not known, or when too many
+// tenants label metrics already.
+const (
+	tenantUnknown = "unknown"
+	tenantOther   = "other"
+)
+
+var (
+	runFunctionCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "function_fluxcd_tenant_crbs_run_function_total",
+		Help: "Number of RunFunction calls, by outcome and tenant.",
+	}, []string{"outcome", "tenant"})
+
+	runFunctionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "function_fluxcd_tenant_crbs_run_function_duration_seconds",
+		Help:    "Duration of RunFunction calls, by outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	composedResources = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "function_fluxcd_tenant_crbs_composed_resources",
+		Help:    "Number of bindings and generated ClusterRoles composed by successful RunFunction calls.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	tenantBindings = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "function_fluxcd_tenant_crbs_tenant_bindings",
+		Help: "Number of bindings of a tenant, as of its last successful RunFunction call.",
+	}, []string{"tenant"})
+
+	discoveryCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "function_fluxcd_tenant_crbs_discovery_requests_total",
+		Help: "Number of ProviderRevision lists from the API server, by outcome.",
+	}, []string{"outcome"})
+
+	discoveryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "function_fluxcd_tenant_crbs_discovery_duration_seconds",
+		Help:    "Duration of ProviderRevision lists from the API server, by outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"outcome"})
+)
+
+// A tenantLabeler bounds the number of distinct tenants labelling metrics.
+type tenantLabeler struct {
+	limit int
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// newTenantLabeler returns a labeler of at most the supplied number of
+// distinct tenants.
+func newTenantLabeler(limit int) *tenantLabeler {
+	return &tenantLabeler{limit: limit, seen: map[string]bool{}}
+}
+
+// Label returns the label of the supplied tenant: the tenant itself if it is
+// among the first tenants labelled, or else tenantOther.
+func (l *tenantLabeler) Label(tenant string) string {
+	if tenant == "" {
+		return tenantUnknown
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seen[tenant] {
+		return tenant
+	}
+	if len(l.seen) >= l.limit {
+		return tenantOther
+	}
+	l.seen[tenant] = true
+	return tenant
+}
+
+// An instrumentedFunction records metrics of the RunFunction calls of the
+// function it wraps.
+type instrumentedFunction struct {
+	fnv1.FunctionRunnerServiceServer
+
+	tenants *tenantLabeler
+}
+
+// RunFunction runs the wrapped function and records the outcome, duration and
+// composed resources of the call. The tenant and its bindings are read from
+// the pipeline context the function published.
+func (f *instrumentedFunction) RunFunction(ctx context.Context, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+	start := time.Now()
+	rsp, err := f.FunctionRunnerServiceServer.RunFunction(ctx, req)
+
+	outcome := runOutcome(rsp, err)
+	runFunctionDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+
+	c := rsp.GetContext().GetFields()[contextKeyBindings].GetStructValue().GetFields()
+	tenant := f.tenants.Label(c["tenantName"].GetStringValue())
+	runFunctionCalls.WithLabelValues(outcome, tenant).Inc()
+
+	if outcome == outcomeFatal || outcome == outcomeError || c == nil {
+		return rsp, err
+	}
+	n := 0
+	for _, r := range rsp.GetDesired().GetResources() {
+		if _, ok := r.GetResource().GetFields()["metadata"].GetStructValue().GetFields()["labels"].GetStructValue().GetFields()[labelBinding]; ok {
+			n++
+		}
+	}
+	composedResources.Observe(float64(n))
+	if tenant != tenantOther && tenant != tenantUnknown {
+		tenantBindings.WithLabelValues(tenant).Set(float64(len(c["bindings"].GetListValue().GetValues())))
+	}
+	return rsp, err
+}
+
+// runOutcome returns the outcome of a RunFunction call that returned the
+// supplied response and error.
+func runOutcome(rsp *fnv1.RunFunctionResponse, err error) string {
+	if err != nil {
+		return outcomeError
+	}
+	outcome := outcomeSuccess
+	for _, r := range rsp.GetResults() {
+		switch r.GetSeverity() {
+		case fnv1.Severity_SEVERITY_FATAL:
+			return outcomeFatal
+		case fnv1.Severity_SEVERITY_WARNING:
+			outcome = outcomeWarning
+		}
+	}
+	return outcome
+}
+
+// observeDiscovery returns the supplied fetchFunc, recording the outcome and
+// duration of each of its calls.
+func observeDiscovery(fetch fetchFunc) fetchFunc {
+	return func(ctx context.Context, log logging.Logger, o listOptions) (*unstructured.UnstructuredList, error) {
+		start := time.Now()
+		l, err := fetch(ctx, log, o)
+		outcome := outcomeSuccess
+		if err != nil {
+			outcome = outcomeError
+		}
+		discoveryDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+		discoveryCalls.WithLabelValues(outcome).Inc()
+		return l, err
+	}
+}
+
+// serveMetrics serves the metrics of the default registry at /metrics of the
+// supplied address, in the background.
+func serveMetrics(log logging.Logger, address string) error {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.Wrap(err, "cannot listen for metrics requests")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Info("Stopped serving metrics", "error", err)
+		}
+	}()
+	return nil
+}
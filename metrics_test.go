@@ -0,0 +1,164 @@
+package main
+
+import (
+	// Standard library imports
+	"context"
+	"testing"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/function-sdk-go/errors"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/response"
+)
+
+func TestTenantLabeler(t *testing.T) {
+	l := newTenantLabeler(2)
+	got := []string{}
+	for _, tenant := range []string{"dev-team", "", "ops-team", "qa-team", "dev-team"} {
+		got = append(got, l.Label(tenant))
+	}
+	want := []string{"dev-team", tenantUnknown, "ops-team", tenantOther, "dev-team"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Label(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestRunOutcome(t *testing.T) {
+	type args struct {
+		rsp *fnv1.RunFunctionResponse
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   string
+	}{
+		"Success": {
+			reason: "A response with Normal results should be a success.",
+			args:   args{rsp: &fnv1.RunFunctionResponse{Results: []*fnv1.Result{{Severity: fnv1.Severity_SEVERITY_NORMAL}}}},
+			want:   outcomeSuccess,
+		},
+		"Warning": {
+			reason: "A response with a Warning result should be a warning.",
+			args:   args{rsp: &fnv1.RunFunctionResponse{Results: []*fnv1.Result{{Severity: fnv1.Severity_SEVERITY_WARNING}, {Severity: fnv1.Severity_SEVERITY_NORMAL}}}},
+			want:   outcomeWarning,
+		},
+		"Fatal": {
+			reason: "A response with a Fatal result should be fatal, even with a Warning result.",
+			args:   args{rsp: &fnv1.RunFunctionResponse{Results: []*fnv1.Result{{Severity: fnv1.Severity_SEVERITY_WARNING}, {Severity: fnv1.Severity_SEVERITY_FATAL}}}},
+			want:   outcomeFatal,
+		},
+		"Error": {
+			reason: "A call that returned an error should be an error.",
+			args:   args{err: errors.New("boom")},
+			want:   outcomeError,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, runOutcome(tc.args.rsp, tc.args.err)); diff != "" {
+				t.Errorf("%s\nrunOutcome(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInstrumentedFunction(t *testing.T) {
+	f := &instrumentedFunction{
+		FunctionRunnerServiceServer: &Function{log: logging.NewNopLogger()},
+		tenants:                     newTenantLabeler(10),
+	}
+	req := &fnv1.RunFunctionRequest{
+		Input: resource.MustStructJSON(`{
+			"apiVersion": "fluxcdtenantcrbs.fn.crossplane.io/v1beta1",
+			"kind": "Input"
+		}`),
+		ExtraResources: map[string]*fnv1.Resources{
+			"providerRevisions": {
+				Items: []*fnv1.Resource{{Resource: resource.MustStructJSON(`{
+					"apiVersion": "pkg.crossplane.io/v1",
+					"kind": "ProviderRevision",
+					"metadata": {
+						"name": "provider-kubernetes-71953a1e5c15",
+						"labels": {"pkg.crossplane.io/package": "provider-kubernetes"}
+					},
+					"spec": {"desiredState": "Active", "revision": 1},
+					"status": {"conditions": [{"type": "Healthy", "status": "True", "reason": "HealthyPackageRevision"}]}
+				}`)}},
+			},
+		},
+		Observed: &fnv1.State{
+			Composite: &fnv1.Resource{Resource: resource.MustStructJSON(`{
+				"apiVersion": "gitops.idp.someorg.com/v1alpha1",
+				"kind": "XFluxcdTenant",
+				"spec": {"tenantName": "metrics-team"}
+			}`)},
+		},
+	}
+
+	calls := testutil.ToFloat64(runFunctionCalls.WithLabelValues(outcomeSuccess, "metrics-team"))
+	if _, err := f.RunFunction(context.Background(), req); err != nil {
+		t.Fatalf("f.RunFunction(...): %v", err)
+	}
+	if got := testutil.ToFloat64(runFunctionCalls.WithLabelValues(outcomeSuccess, "metrics-team")) - calls; got != 1 {
+		t.Errorf("f.RunFunction(...): want 1 successful call of tenant metrics-team, got %v", got)
+	}
+	if got := testutil.ToFloat64(tenantBindings.WithLabelValues("metrics-team")); got != 1 {
+		t.Errorf("f.RunFunction(...): want 1 binding of tenant metrics-team, got %v", got)
+	}
+
+	// A Fatal response publishes no tenant.
+	fatal := testutil.ToFloat64(runFunctionCalls.WithLabelValues(outcomeFatal, tenantUnknown))
+	f.FunctionRunnerServiceServer = &fakeRunner{Fn: func(_ context.Context, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+		rsp := response.To(req, response.DefaultTTL)
+		response.Fatal(rsp, errors.New("boom"))
+		return rsp, nil
+	}}
+	if _, err := f.RunFunction(context.Background(), req); err != nil {
+		t.Fatalf("f.RunFunction(...): %v", err)
+	}
+	if got := testutil.ToFloat64(runFunctionCalls.WithLabelValues(outcomeFatal, tenantUnknown)) - fatal; got != 1 {
+		t.Errorf("f.RunFunction(...): want 1 fatal call of an unknown tenant, got %v", got)
+	}
+}
+
+func TestObserveDiscovery(t *testing.T) {
+	ok := testutil.ToFloat64(discoveryCalls.WithLabelValues(outcomeSuccess))
+	failed := testutil.ToFloat64(discoveryCalls.WithLabelValues(outcomeError))
+
+	var err error
+	fetch := observeDiscovery(func(_ context.Context, _ logging.Logger, _ listOptions) (*unstructured.UnstructuredList, error) {
+		return &unstructured.UnstructuredList{}, err
+	})
+	_, _ = fetch(context.Background(), logging.NewNopLogger(), listOptions{})
+	err = errors.New("boom")
+	_, _ = fetch(context.Background(), logging.NewNopLogger(), listOptions{})
+
+	if got := testutil.ToFloat64(discoveryCalls.WithLabelValues(outcomeSuccess)) - ok; got != 1 {
+		t.Errorf("fetch(...): want 1 successful discovery call, got %v", got)
+	}
+	if got := testutil.ToFloat64(discoveryCalls.WithLabelValues(outcomeError)) - failed; got != 1 {
+		t.Errorf("fetch(...): want 1 failed discovery call, got %v", got)
+	}
+}
+
+// A fakeRunner runs a function by calling Fn.
+type fakeRunner struct {
+	fnv1.UnimplementedFunctionRunnerServiceServer
+
+	Fn func(ctx context.Context, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error)
+}
+
+// RunFunction calls Fn.
+func (r *fakeRunner) RunFunction(ctx context.Context, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+	return r.Fn(ctx, req)
+}
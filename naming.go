@@ -0,0 +1,34 @@
+package main
+
+import (
+	// Standard library imports
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+// nameHashLength is the number of hex characters of the hash appended to a
+// shortened name.
+const nameHashLength = 8
+
+// shortenName returns the supplied name if it is at most maxLength characters
+// long. Longer names are truncated and suffixed with a short hash of the full
+// name, so that distinct names sharing a long prefix remain distinct and the
+// same name is always shortened the same way.
+func shortenName(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+
+	// Don't split a multi-byte character, nor leave a separator dangling
+	// before the one we add.
+	cut := maxLength - nameHashLength - 1
+	for cut > 0 && !utf8.RuneStart(name[cut]) {
+		cut--
+	}
+	prefix := strings.TrimRight(name[:cut], "-.")
+	return prefix + "-" + hash
+}
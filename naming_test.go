@@ -0,0 +1,103 @@
+package main
+
+import (
+	// Standard library imports
+	"strings"
+	"testing"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestShortenName(t *testing.T) {
+	long := "a-very-long-fluxcd-tenant-name-for-the-platform-team"
+
+	type args struct {
+		name      string
+		maxLength int
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   string
+	}{
+		"ShortName": {
+			reason: "A name within the maximum length should be returned unchanged.",
+			args: args{
+				name:      "demo000-provider-kubernetes-edit",
+				maxLength: 63,
+			},
+			want: "demo000-provider-kubernetes-edit",
+		},
+		"ExactLength": {
+			reason: "A name of exactly the maximum length should be returned unchanged.",
+			args: args{
+				name:      "demo000-provider-kubernetes-edit",
+				maxLength: len("demo000-provider-kubernetes-edit"),
+			},
+			want: "demo000-provider-kubernetes-edit",
+		},
+		"LongName": {
+			reason: "A name longer than the maximum length should be truncated and suffixed with a hash of the full name.",
+			args: args{
+				name:      long + "-provider-family-azure-edit",
+				maxLength: 63,
+			},
+			want: "a-very-long-fluxcd-tenant-name-for-the-platform-team-p-8508c5e1",
+		},
+		"TrailingSeparator": {
+			reason: "A truncated name should not end with a separator before the hash.",
+			args: args{
+				name:      "demo000-provider-kubernetes-edit",
+				maxLength: 17,
+			},
+			want: "demo000-63f1332c",
+		},
+		"MultiByteCharacter": {
+			reason: "A truncated name should not end with part of a multi-byte character.",
+			args: args{
+				name:      "demo000ñandú-provider-kubernetes-edit",
+				maxLength: 17,
+			},
+			want: "demo000-2f95642e",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := shortenName(tc.args.name, tc.args.maxLength)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("%s\nshortenName(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if len(got) > tc.args.maxLength {
+				t.Errorf("%s\nshortenName(...): len(%q) = %d, want at most %d", tc.reason, got, len(got), tc.args.maxLength)
+			}
+		})
+	}
+}
+
+func TestShortenNameCollisions(t *testing.T) {
+	prefix := strings.Repeat("tenant", 10)
+
+	// Names that only differ after the truncation point must not collide.
+	names := []string{
+		prefix + "-provider-azure-network-edit",
+		prefix + "-provider-azure-storage-edit",
+		prefix + "-provider-azure-network-view",
+		prefix + "-provider-family-azure-edit",
+	}
+
+	seen := map[string]string{}
+	for _, n := range names {
+		got := shortenName(n, 63)
+		if other, ok := seen[got]; ok {
+			t.Errorf("shortenName(%q) and shortenName(%q) both returned %q", n, other, got)
+		}
+		seen[got] = n
+
+		if again := shortenName(n, 63); again != got {
+			t.Errorf("shortenName(%q) is not deterministic: got %q then %q", n, got, again)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	// Standard library imports
+	"sort"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+
+	"github.com/chelala/function-fluxcd-tenant-crossplane-providers-usage-resource-crbs/input/v1beta1"
+)
+
+// labelBinding labels every composed resource the function composes with the
+// name of the binding it belongs to. It identifies the observed composed
+// resources the function composed earlier.
+const labelBinding = "fluxcdtenantcrbs.fn.crossplane.io/binding"
+
+// A prunePlan lists the bindings the function composed earlier that are no
+// longer desired.
+type prunePlan struct {
+	// Removed bindings, whose composed resources Crossplane deletes.
+	Removed []string
+
+	// Retained bindings, whose observed composed resources stay desired
+	// until they are released.
+	Retained []string
+}
+
+// prune plans the removal of the observed bindings that are not among the
+// supplied desired composed resources. Per the input PrunePolicy, it either
+// lets Crossplane delete them or adds their observed composed resources back
+// to the desired composed resources, unless they are released.
+func prune(in *v1beta1.Input, observed map[resource.Name]resource.ObservedComposed, desired map[resource.Name]*resource.DesiredComposed, released []string) prunePlan {
+	isReleased := map[string]bool{}
+	for _, name := range released {
+		isReleased[name] = true
+	}
+
+	removed, retained := map[string]bool{}, map[string]bool{}
+	for name, oc := range observed {
+		b, ok := oc.Resource.GetLabels()[labelBinding]
+		if !ok {
+			continue
+		}
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		if in.PrunePolicy != v1beta1.PrunePolicyRetain || isReleased[b] {
+			removed[b] = true
+			continue
+		}
+		desired[name] = &resource.DesiredComposed{Resource: retain(oc.Resource)}
+		retained[b] = true
+	}
+	return prunePlan{Removed: sortedKeys(removed), Retained: sortedKeys(retained)}
+}
+
+// retain returns the desired state of the supplied observed composed
+// resource: everything but its status and the metadata set by the API server.
+func retain(oc *composed.Unstructured) *composed.Unstructured {
+	c := oc.DeepCopy()
+	u := composed.New()
+	for k, v := range c.Object {
+		if k == "metadata" || k == "status" {
+			continue
+		}
+		u.Object[k] = v
+	}
+	if name := c.GetName(); name != "" {
+		u.SetName(name)
+	}
+	u.SetLabels(c.GetLabels())
+	u.SetAnnotations(c.GetAnnotations())
+	return u
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// added returns the names of the supplied bindings that are not among the
+// supplied observed composed resources yet, in order.
+func added(bindings []binding, observed map[resource.Name]resource.ObservedComposed) []string {
+	out := []string{}
+	for _, b := range bindings {
+		if _, ok := observed[resource.Name(b.Name)]; !ok {
+			out = append(out, b.Name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	// Standard library imports
+	"testing"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+
+	"github.com/chelala/function-fluxcd-tenant-crossplane-providers-usage-resource-crbs/input/v1beta1"
+)
+
+// observedObject returns an observed provider-kubernetes Object of the
+// supplied binding, or of no binding when it is empty.
+func observedObject(name, bindingName string) resource.ObservedComposed {
+	u := &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+		"kind":       "Object",
+		"metadata": map[string]interface{}{
+			"name":            name,
+			"uid":             "08f4c121-2953-43e6-a45f-088d311169bb",
+			"resourceVersion": "42",
+		},
+		"spec": map[string]interface{}{
+			"forProvider": map[string]interface{}{
+				"manifest": map[string]interface{}{"kind": "ClusterRoleBinding"},
+			},
+		},
+		"status": map[string]interface{}{
+			"atProvider": map[string]interface{}{},
+		},
+	}}}
+	if bindingName != "" {
+		u.SetLabels(map[string]string{labelBinding: bindingName})
+	}
+	return resource.ObservedComposed{Resource: u}
+}
+
+func TestPrune(t *testing.T) {
+	observed := map[resource.Name]resource.ObservedComposed{
+		"dev-team-provider-kubernetes-edit": observedObject("xr-abcde", "dev-team-provider-kubernetes-edit"),
+		"dev-team-provider-helm-edit":       observedObject("xr-fghij", "dev-team-provider-helm-edit"),
+		"dev-team-provider-aws-edit":        observedObject("xr-klmno", "dev-team-provider-aws-edit"),
+		"other":                             observedObject("xr-pqrst", ""),
+	}
+
+	type args struct {
+		policy   v1beta1.PrunePolicy
+		released []string
+	}
+	type want struct {
+		plan    prunePlan
+		desired []resource.Name
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Delete": {
+			reason: "Observed bindings that are no longer desired should be removed.",
+			args: args{
+				policy: v1beta1.PrunePolicyDelete,
+			},
+			want: want{
+				plan:    prunePlan{Removed: []string{"dev-team-provider-aws-edit", "dev-team-provider-helm-edit"}, Retained: []string{}},
+				desired: []resource.Name{"dev-team-provider-kubernetes-edit"},
+			},
+		},
+		"Retain": {
+			reason: "Observed bindings that are no longer desired should be retained until released.",
+			args: args{
+				policy:   v1beta1.PrunePolicyRetain,
+				released: []string{"dev-team-provider-aws-edit"},
+			},
+			want: want{
+				plan:    prunePlan{Removed: []string{"dev-team-provider-aws-edit"}, Retained: []string{"dev-team-provider-helm-edit"}},
+				desired: []resource.Name{"dev-team-provider-helm-edit", "dev-team-provider-kubernetes-edit"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			desired := map[resource.Name]*resource.DesiredComposed{
+				"dev-team-provider-kubernetes-edit": {Resource: composed.New()},
+			}
+			plan := prune(&v1beta1.Input{PrunePolicy: tc.args.policy}, observed, desired, tc.args.released)
+			if diff := cmp.Diff(tc.want.plan, plan); diff != "" {
+				t.Errorf("%s\nprune(...): -want plan, +got plan:\n%s", tc.reason, diff)
+			}
+			got := []resource.Name{}
+			for name := range desired {
+				got = append(got, name)
+			}
+			if diff := cmp.Diff(tc.want.desired, got, cmpopts.SortSlices(func(a, b resource.Name) bool { return a < b })); diff != "" {
+				t.Errorf("%s\nprune(...): -want desired, +got desired:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRetain(t *testing.T) {
+	got := retain(observedObject("xr-abcde", "dev-team-provider-kubernetes-edit").Resource)
+	want := map[string]interface{}{
+		"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+		"kind":       "Object",
+		"metadata": map[string]interface{}{
+			"name": "xr-abcde",
+			"labels": map[string]interface{}{
+				labelBinding: "dev-team-provider-kubernetes-edit",
+			},
+		},
+		"spec": map[string]interface{}{
+			"forProvider": map[string]interface{}{
+				"manifest": map[string]interface{}{"kind": "ClusterRoleBinding"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got.Object); diff != "" {
+		t.Errorf("retain(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	// Standard library imports
+	"sort"
+	"strings"
+
+	// Default imports (third-party packages not matching other prefixes)
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	// Imports with prefix github.com/crossplane
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+)
+
+const (
+	labelPackage = "pkg.crossplane.io/package"
+
+	// labelProviderFamily labels the revisions of every member of a provider
+	// family, and of the family config provider itself, with the package name
+	// of the family config provider.
+	labelProviderFamily = "pkg.crossplane.io/provider-family"
+
+	// crdProviderConfigUsages prefixes the name of the ProviderConfigUsage
+	// CustomResourceDefinition of every provider.
+	crdProviderConfigUsages = "providerconfigusages."
+
+	desiredStateActive = "Active"
+	conditionHealthy   = xpv1.ConditionType("Healthy")
+)
+
+// A boundRevision is a ProviderRevision the tenant is bound to.
+type boundRevision struct {
+	*unstructured.Unstructured
+
+	// Package is the package the ProviderRevision is a revision of.
+	Package string
+
+	// Primary is true for the single revision chosen for the package, whose
+	// binding is named after the package. Every other bound revision of the
+	// package is an inactive revision bound during a rollover, whose binding
+	// is also named after the revision.
+	Primary bool
+}
+
+// revisionStatus is the part of a ProviderRevision used to select it.
+type revisionStatus struct {
+	Active   bool
+	Healthy  bool
+	Revision int64
+}
+
+func getRevisionStatus(pr *unstructured.Unstructured) revisionStatus {
+	p := fieldpath.Pave(pr.Object)
+
+	spec := struct {
+		DesiredState string `json:"desiredState"`
+		Revision     int64  `json:"revision"`
+	}{}
+	_ = p.GetValueInto("spec", &spec)
+
+	cs := xpv1.ConditionedStatus{}
+	_ = p.GetValueInto("status", &cs)
+
+	return revisionStatus{
+		Active:   spec.DesiredState == desiredStateActive,
+		Healthy:  cs.GetCondition(conditionHealthy).Status == corev1.ConditionTrue,
+		Revision: spec.Revision,
+	}
+}
+
+// selectProviderRevisions returns the healthy ProviderRevisions the tenant
+// should be bound to, ordered by package. It chooses a single active revision
+// per package: the one with the highest revision number, breaking ties by
+// name. Inactive revisions are skipped unless includeInactive is true, in
+// which case they are returned after the primary revision of their package.
+// ProviderRevisions without a package label are skipped.
+func selectProviderRevisions(prs []unstructured.Unstructured, includeInactive bool) []boundRevision {
+	type candidate struct {
+		boundRevision
+		status revisionStatus
+	}
+
+	byPackage := map[string][]candidate{}
+	for i := range prs {
+		pr := &prs[i]
+		pkg := pr.GetLabels()[labelPackage]
+		if pkg == "" {
+			continue
+		}
+		s := getRevisionStatus(pr)
+		if !s.Healthy || (!s.Active && !includeInactive) {
+			continue
+		}
+		byPackage[pkg] = append(byPackage[pkg], candidate{boundRevision: boundRevision{Unstructured: pr, Package: pkg}, status: s})
+	}
+
+	pkgs := make([]string, 0, len(byPackage))
+	for pkg := range byPackage {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	out := make([]boundRevision, 0, len(prs))
+	for _, pkg := range pkgs {
+		cs := byPackage[pkg]
+		sort.Slice(cs, func(i, j int) bool {
+			if cs[i].status.Active != cs[j].status.Active {
+				return cs[i].status.Active
+			}
+			if cs[i].status.Revision != cs[j].status.Revision {
+				return cs[i].status.Revision > cs[j].status.Revision
+			}
+			return cs[i].GetName() > cs[j].GetName()
+		})
+		for i, c := range cs {
+			if i > 0 && c.status.Active {
+				// Only one active revision is bound per package.
+				continue
+			}
+			c.Primary = i == 0
+			out = append(out, c.boundRevision)
+		}
+	}
+	return out
+}
+
+// withFamilyParents returns the supplied selected ProviderRevisions, plus
+// every revision of the family config provider of each selected member of a
+// provider family, unless skip returns true for the family config provider.
+// It also returns the family config providers it added, in order.
+func withFamilyParents(selected, all []unstructured.Unstructured, skip func(pkg string) bool) ([]unstructured.Unstructured, []string) {
+	present := map[string]bool{}
+	for i := range selected {
+		present[selected[i].GetLabels()[labelPackage]] = true
+	}
+
+	parents := map[string]bool{}
+	for i := range selected {
+		l := selected[i].GetLabels()
+		if f := l[labelProviderFamily]; f != "" && f != l[labelPackage] && !present[f] && !skip(f) {
+			parents[f] = true
+		}
+	}
+	if len(parents) == 0 {
+		return selected, nil
+	}
+
+	out := append(make([]unstructured.Unstructured, 0, len(selected)+len(parents)), selected...)
+	added := map[string]bool{}
+	for i := range all {
+		pkg := all[i].GetLabels()[labelPackage]
+		if !parents[pkg] {
+			continue
+		}
+		out = append(out, all[i])
+		added[pkg] = true
+	}
+
+	pkgs := make([]string, 0, len(added))
+	for pkg := range added {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	return out, pkgs
+}
+
+// unboundFamilyParents returns the family config providers of the supplied
+// bound members of provider families that are not bound themselves, like
+// those without a healthy revision, unless skip returns true for them.
+func unboundFamilyParents(bound []boundRevision, skip func(pkg string) bool) []string {
+	present := map[string]bool{}
+	for _, pr := range bound {
+		present[pr.Package] = true
+	}
+
+	missing := map[string]bool{}
+	for _, pr := range bound {
+		if f := pr.GetLabels()[labelProviderFamily]; f != "" && !present[f] && !skip(f) {
+			missing[f] = true
+		}
+	}
+
+	pkgs := make([]string, 0, len(missing))
+	for pkg := range missing {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// providerConfigUsageGroups returns the API groups of the ProviderConfigUsage
+// types the supplied ProviderRevision installs, per its status.objectRefs.
+func providerConfigUsageGroups(pr *unstructured.Unstructured) []string {
+	refs := []xpv1.TypedReference{}
+	_ = fieldpath.Pave(pr.Object).GetValueInto("status.objectRefs", &refs)
+
+	groups := []string{}
+	for _, ref := range refs {
+		if ref.Kind != "CustomResourceDefinition" || !strings.HasPrefix(ref.Name, crdProviderConfigUsages) {
+			continue
+		}
+		groups = append(groups, strings.TrimPrefix(ref.Name, crdProviderConfigUsages))
+	}
+	sort.Strings(groups)
+	return groups
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	// Standard library imports
+	"testing"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// revision returns a ProviderRevision of the supplied package.
+func revision(name, pkg, desiredState string, rev int64, healthy bool) unstructured.Unstructured {
+	status := "False"
+	if healthy {
+		status = "True"
+	}
+	return unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "pkg.crossplane.io/v1",
+			"kind":       "ProviderRevision",
+			"metadata": map[string]interface{}{
+				"name": name,
+				"labels": map[string]interface{}{
+					"pkg.crossplane.io/package": pkg,
+				},
+			},
+			"spec": map[string]interface{}{
+				"desiredState": desiredState,
+				"revision":     rev,
+			},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":               "Healthy",
+						"status":             status,
+						"reason":             "HealthyPackageRevision",
+						"lastTransitionTime": "2024-12-12T19:03:42Z",
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSelectProviderRevisions(t *testing.T) {
+	type bound struct {
+		Name    string
+		Package string
+		Primary bool
+	}
+
+	type args struct {
+		prs             []unstructured.Unstructured
+		includeInactive bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   []bound
+	}{
+		"ActiveHealthyOnly": {
+			reason: "Inactive and unhealthy revisions should not be bound.",
+			args: args{
+				prs: []unstructured.Unstructured{
+					revision("provider-kubernetes-aaa", "provider-kubernetes", "Inactive", 1, true),
+					revision("provider-kubernetes-bbb", "provider-kubernetes", "Active", 2, true),
+					revision("provider-helm-ccc", "provider-helm", "Active", 1, false),
+				},
+			},
+			want: []bound{
+				{Name: "provider-kubernetes-bbb", Package: "provider-kubernetes", Primary: true},
+			},
+		},
+		"SingleRevisionPerPackage": {
+			reason: "The highest active revision of a package should be bound, breaking ties by name.",
+			args: args{
+				prs: []unstructured.Unstructured{
+					revision("provider-kubernetes-aaa", "provider-kubernetes", "Active", 1, true),
+					revision("provider-kubernetes-ccc", "provider-kubernetes", "Active", 2, true),
+					revision("provider-helm-aaa", "provider-helm", "Active", 1, true),
+					revision("provider-helm-bbb", "provider-helm", "Active", 1, true),
+				},
+			},
+			want: []bound{
+				{Name: "provider-helm-bbb", Package: "provider-helm", Primary: true},
+				{Name: "provider-kubernetes-ccc", Package: "provider-kubernetes", Primary: true},
+			},
+		},
+		"IncludeInactive": {
+			reason: "Healthy inactive revisions should be bound after the active revision when requested.",
+			args: args{
+				prs: []unstructured.Unstructured{
+					revision("provider-kubernetes-aaa", "provider-kubernetes", "Inactive", 1, true),
+					revision("provider-kubernetes-bbb", "provider-kubernetes", "Active", 2, true),
+					revision("provider-kubernetes-ccc", "provider-kubernetes", "Inactive", 0, false),
+				},
+				includeInactive: true,
+			},
+			want: []bound{
+				{Name: "provider-kubernetes-bbb", Package: "provider-kubernetes", Primary: true},
+				{Name: "provider-kubernetes-aaa", Package: "provider-kubernetes", Primary: false},
+			},
+		},
+		"NoPackageLabel": {
+			reason: "Revisions without a package label should not be bound.",
+			args: args{
+				prs: []unstructured.Unstructured{
+					revision("provider-kubernetes-aaa", "", "Active", 1, true),
+				},
+			},
+			want: []bound{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := []bound{}
+			for _, br := range selectProviderRevisions(tc.args.prs, tc.args.includeInactive) {
+				got = append(got, bound{Name: br.GetName(), Package: br.Package, Primary: br.Primary})
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("%s\nselectProviderRevisions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// familyRevision returns a ProviderRevision of the supplied member of a
+// provider family.
+func familyRevision(name, pkg, family string) unstructured.Unstructured {
+	pr := revision(name, pkg, "Active", 1, true)
+	pr.SetLabels(map[string]string{labelPackage: pkg, labelProviderFamily: family})
+	return pr
+}
+
+func TestWithFamilyParents(t *testing.T) {
+	all := []unstructured.Unstructured{
+		familyRevision("provider-family-azure-aaa", "provider-family-azure", "provider-family-azure"),
+		familyRevision("provider-azure-network-aaa", "provider-azure-network", "provider-family-azure"),
+		familyRevision("provider-family-aws-aaa", "provider-family-aws", "provider-family-aws"),
+		familyRevision("provider-aws-s3-aaa", "provider-aws-s3", "provider-family-aws"),
+		revision("provider-kubernetes-aaa", "provider-kubernetes", "Active", 1, true),
+	}
+
+	type args struct {
+		selected []unstructured.Unstructured
+		skip     func(pkg string) bool
+	}
+	type want struct {
+		Names   []string
+		Parents []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"AddParent": {
+			reason: "The family config provider of a selected member should be added.",
+			args: args{
+				selected: []unstructured.Unstructured{all[1], all[4]},
+				skip:     func(string) bool { return false },
+			},
+			want: want{
+				Names:   []string{"provider-azure-network-aaa", "provider-kubernetes-aaa", "provider-family-azure-aaa"},
+				Parents: []string{"provider-family-azure"},
+			},
+		},
+		"ParentAlreadySelected": {
+			reason: "A family config provider that is already selected should not be added again.",
+			args: args{
+				selected: []unstructured.Unstructured{all[0], all[1]},
+				skip:     func(string) bool { return false },
+			},
+			want: want{
+				Names: []string{"provider-family-azure-aaa", "provider-azure-network-aaa"},
+			},
+		},
+		"SkipParent": {
+			reason: "A skipped family config provider should not be added.",
+			args: args{
+				selected: []unstructured.Unstructured{all[1], all[3]},
+				skip:     func(pkg string) bool { return pkg == "provider-family-azure" },
+			},
+			want: want{
+				Names:   []string{"provider-azure-network-aaa", "provider-aws-s3-aaa", "provider-family-aws-aaa"},
+				Parents: []string{"provider-family-aws"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			prs, parents := withFamilyParents(tc.args.selected, all, tc.args.skip)
+			got := want{Parents: parents}
+			for _, pr := range prs {
+				got.Names = append(got.Names, pr.GetName())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("%s\nwithFamilyParents(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestUnboundFamilyParents(t *testing.T) {
+	azure := familyRevision("provider-family-azure-aaa", "provider-family-azure", "provider-family-azure")
+	network := familyRevision("provider-azure-network-aaa", "provider-azure-network", "provider-family-azure")
+	s3 := familyRevision("provider-aws-s3-aaa", "provider-aws-s3", "provider-family-aws")
+	kubernetes := revision("provider-kubernetes-aaa", "provider-kubernetes", "Active", 1, true)
+	bound := func(prs ...unstructured.Unstructured) []boundRevision {
+		out := make([]boundRevision, 0, len(prs))
+		for i := range prs {
+			out = append(out, boundRevision{Unstructured: &prs[i], Package: prs[i].GetLabels()[labelPackage], Primary: true})
+		}
+		return out
+	}
+
+	type args struct {
+		bound []boundRevision
+		skip  func(pkg string) bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   []string
+	}{
+		"ParentBound": {
+			reason: "A family config provider bound with its member should not be returned.",
+			args: args{
+				bound: bound(azure, network, kubernetes),
+				skip:  func(string) bool { return false },
+			},
+			want: []string{},
+		},
+		"ParentUnbound": {
+			reason: "The family config provider of a bound member should be returned when it is not bound.",
+			args: args{
+				bound: bound(network, s3, kubernetes),
+				skip:  func(string) bool { return false },
+			},
+			want: []string{"provider-family-aws", "provider-family-azure"},
+		},
+		"SkipParent": {
+			reason: "A skipped family config provider should not be returned.",
+			args: args{
+				bound: bound(network, s3),
+				skip:  func(pkg string) bool { return pkg == "provider-family-azure" },
+			},
+			want: []string{"provider-family-aws"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := unboundFamilyParents(tc.args.bound, tc.args.skip)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("%s\nunboundFamilyParents(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	// Standard library imports
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	// Default imports (third-party packages not matching other prefixes)
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/function-sdk-go/errors"
+
+	"github.com/chelala/function-fluxcd-tenant-crossplane-providers-usage-resource-crbs/input/v1beta1"
+)
+
+// Reasons a ProviderRevision is excluded by a providerFilter.
+const (
+	reasonNotSelected = "not selected"
+	reasonDenied      = "denied"
+)
+
+// A providerFilter evaluates a ProviderSelector.
+type providerFilter struct {
+	selector labels.Selector
+	packages []string
+	patterns []*regexp.Regexp
+	deny     []string
+}
+
+// newProviderFilter returns a filter evaluating the supplied selector. A nil
+// selector selects every ProviderRevision.
+func newProviderFilter(s *v1beta1.ProviderSelector) (*providerFilter, error) {
+	f := &providerFilter{selector: labels.Everything()}
+	if s == nil {
+		return f, nil
+	}
+
+	sel, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchLabels: s.MatchLabels, MatchExpressions: s.MatchExpressions})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid label selector")
+	}
+	f.selector = sel
+
+	for _, p := range append(append([]string{}, s.Packages...), s.Deny...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid package glob %q", p)
+		}
+	}
+	f.packages = s.Packages
+	f.deny = s.Deny
+
+	for _, p := range s.PackagePatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid package pattern %q", p)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	return f, nil
+}
+
+// Filter returns the supplied ProviderRevisions the filter selects, and the
+// reason every package it excluded was excluded.
+func (f *providerFilter) Filter(prs []unstructured.Unstructured) ([]unstructured.Unstructured, map[string]string) {
+	selected := make([]unstructured.Unstructured, 0, len(prs))
+	excluded := map[string]string{}
+	for _, pr := range prs {
+		pkg := pr.GetLabels()[labelPackage]
+		if reason := f.exclude(pkg, labels.Set(pr.GetLabels())); reason != "" {
+			if pkg != "" {
+				excluded[pkg] = reason
+			}
+			continue
+		}
+		selected = append(selected, pr)
+	}
+	return selected, excluded
+}
+
+// exclude returns why a ProviderRevision of the supplied package with the
+// supplied labels is excluded, or an empty string if it is selected.
+func (f *providerFilter) exclude(pkg string, l labels.Set) string {
+	if f.Denied(pkg) {
+		return reasonDenied
+	}
+	if !f.selector.Matches(l) {
+		return reasonNotSelected
+	}
+	if len(f.packages) == 0 && len(f.patterns) == 0 {
+		return ""
+	}
+	if matchesAnyGlob(f.packages, pkg) {
+		return ""
+	}
+	for _, re := range f.patterns {
+		if re.MatchString(pkg) {
+			return ""
+		}
+	}
+	return reasonNotSelected
+}
+
+// Denied returns true if the supplied package is denied.
+func (f *providerFilter) Denied(pkg string) bool {
+	return matchesAnyGlob(f.deny, pkg)
+}
+
+func matchesAnyGlob(globs []string, s string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedMessage describes the supplied excluded packages, in order.
+func excludedMessage(excluded map[string]string) string {
+	pkgs := make([]string, 0, len(excluded))
+	for pkg := range excluded {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	msgs := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		msgs = append(msgs, fmt.Sprintf("%s (%s)", pkg, excluded[pkg]))
+	}
+	return strings.Join(msgs, ", ")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	// Standard library imports
+	"testing"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/chelala/function-fluxcd-tenant-crossplane-providers-usage-resource-crbs/input/v1beta1"
+)
+
+func TestProviderFilter(t *testing.T) {
+	prs := []unstructured.Unstructured{
+		revision("provider-kubernetes-aaa", "provider-kubernetes", "Active", 1, true),
+		revision("provider-helm-aaa", "provider-helm", "Active", 1, true),
+		revision("provider-azure-network-aaa", "provider-azure-network", "Active", 1, true),
+		revision("provider-aws-s3-aaa", "provider-aws-s3", "Active", 1, true),
+	}
+
+	type want struct {
+		selected []string
+		excluded map[string]string
+	}
+
+	cases := map[string]struct {
+		reason   string
+		selector *v1beta1.ProviderSelector
+		want     want
+	}{
+		"NilSelector": {
+			reason: "A nil selector should select every provider.",
+			want: want{
+				selected: []string{"provider-kubernetes-aaa", "provider-helm-aaa", "provider-azure-network-aaa", "provider-aws-s3-aaa"},
+				excluded: map[string]string{},
+			},
+		},
+		"MatchExpressions": {
+			reason: "Providers not matching the label expressions should be excluded.",
+			selector: &v1beta1.ProviderSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: labelPackage, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"provider-helm", "provider-aws-s3"}},
+				},
+			},
+			want: want{
+				selected: []string{"provider-kubernetes-aaa", "provider-azure-network-aaa"},
+				excluded: map[string]string{"provider-helm": reasonNotSelected, "provider-aws-s3": reasonNotSelected},
+			},
+		},
+		"PackagesAndPatterns": {
+			reason: "Providers matching any package glob or pattern should be selected.",
+			selector: &v1beta1.ProviderSelector{
+				Packages:        []string{"provider-azure-*"},
+				PackagePatterns: []string{"^provider-(aws|gcp)-"},
+			},
+			want: want{
+				selected: []string{"provider-azure-network-aaa", "provider-aws-s3-aaa"},
+				excluded: map[string]string{"provider-kubernetes": reasonNotSelected, "provider-helm": reasonNotSelected},
+			},
+		},
+		"Deny": {
+			reason: "Denied providers should be excluded even when otherwise selected.",
+			selector: &v1beta1.ProviderSelector{
+				Packages: []string{"provider-*"},
+				Deny:     []string{"provider-helm", "provider-aws-*"},
+			},
+			want: want{
+				selected: []string{"provider-kubernetes-aaa", "provider-azure-network-aaa"},
+				excluded: map[string]string{"provider-helm": reasonDenied, "provider-aws-s3": reasonDenied},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f, err := newProviderFilter(tc.selector)
+			if err != nil {
+				t.Fatalf("newProviderFilter(...): %v", err)
+			}
+			selected, excluded := f.Filter(prs)
+			got := want{selected: []string{}, excluded: excluded}
+			for _, pr := range selected {
+				got.selected = append(got.selected, pr.GetName())
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("%s\nFilter(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestExcludedMessage(t *testing.T) {
+	got := excludedMessage(map[string]string{"provider-helm": reasonDenied, "provider-aws-s3": reasonNotSelected})
+	want := "provider-aws-s3 (not selected), provider-helm (denied)"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("excludedMessage(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	// Standard library imports
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/function-sdk-go/errors"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/request"
+)
+
+// Kinds of RevisionSource selectable with the --revision-source flag.
+const (
+	revisionSourceExtraResources = "extra-resources"
+	revisionSourceAPI            = "api"
+	revisionSourceFile           = "file"
+)
+
+// A RevisionSource supplies the ProviderRevisions of the cluster.
+type RevisionSource interface {
+	// ProviderRevisions returns the ProviderRevisions per the supplied
+	// options, or nil when they are not known yet. A source may return
+	// ProviderRevisions the options do not select.
+	ProviderRevisions(ctx context.Context, req *fnv1.RunFunctionRequest, o listOptions) (*unstructured.UnstructuredList, error)
+}
+
+// An extraResourcesSource supplies the ProviderRevisions Crossplane supplied
+// as extra resources of the request.
+type extraResourcesSource struct{}
+
+// ProviderRevisions returns the ProviderRevisions Crossplane supplied as extra
+// resources, or nil when it did not supply them yet.
+func (extraResourcesSource) ProviderRevisions(_ context.Context, req *fnv1.RunFunctionRequest, _ listOptions) (*unstructured.UnstructuredList, error) {
+	extras, err := request.GetExtraResources(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get extra resources")
+	}
+	extra, ok := extras[extraResourcesProviderRevisions]
+	if !ok {
+		return nil, nil
+	}
+	l := &unstructured.UnstructuredList{Items: make([]unstructured.Unstructured, 0, len(extra))}
+	for _, e := range extra {
+		l.Items = append(l.Items, *e.Resource)
+	}
+	return l, nil
+}
+
+// An apiSource supplies the ProviderRevisions listed from the API server.
+type apiSource struct {
+	log   logging.Logger
+	fetch fetchFunc
+}
+
+// ProviderRevisions lists ProviderRevisions from the API server.
+func (s *apiSource) ProviderRevisions(ctx context.Context, _ *fnv1.RunFunctionRequest, o listOptions) (*unstructured.UnstructuredList, error) {
+	return s.fetch(ctx, s.log, o)
+}
+
+// A fileSource supplies the ProviderRevisions read from YAML or JSON files.
+type fileSource struct {
+	list *unstructured.UnstructuredList
+}
+
+// newFileSource returns a source of the ProviderRevisions in the supplied
+// file, or in the .yaml, .yml and .json files of the supplied directory. Each
+// file may hold several documents. Documents of other kinds are ignored.
+func newFileSource(path string) (*fileSource, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read ProviderRevisions")
+	}
+	files := []string{path}
+	if fi.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read ProviderRevisions directory %s", path)
+		}
+		files = files[:0]
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".yaml", ".yml", ".json":
+				if !e.IsDir() {
+					files = append(files, filepath.Join(path, e.Name()))
+				}
+			}
+		}
+		sort.Strings(files)
+	}
+
+	l := &unstructured.UnstructuredList{}
+	for _, name := range files {
+		f, err := os.Open(filepath.Clean(name))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read ProviderRevisions")
+		}
+		prs, err := decodeProviderRevisions(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot decode ProviderRevisions from %s", name)
+		}
+		l.Items = append(l.Items, prs...)
+	}
+	return &fileSource{list: l}, nil
+}
+
+// ProviderRevisions returns a copy of the ProviderRevisions read from files.
+func (s *fileSource) ProviderRevisions(_ context.Context, _ *fnv1.RunFunctionRequest, o listOptions) (*unstructured.UnstructuredList, error) {
+	return selectList(s.list, o), nil
+}
+
+// decodeProviderRevisions returns the ProviderRevisions in the supplied stream
+// of YAML or JSON documents. Empty documents and documents of other kinds are
+// ignored. It returns an error naming the first malformed document.
+func decodeProviderRevisions(r io.Reader) ([]unstructured.Unstructured, error) {
+	prs := []unstructured.Unstructured{}
+	yr := yaml.NewYAMLReader(bufio.NewReader(r))
+	for i := 1; ; i++ {
+		doc, err := yr.Read()
+		if errors.Is(err, io.EOF) {
+			return prs, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read document %d", i)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		u := unstructured.Unstructured{}
+		if err := yaml.Unmarshal(doc, &u.Object); err != nil {
+			return nil, errors.Wrapf(err, "document %d is not valid YAML or JSON", i)
+		}
+		if u.Object == nil {
+			// The document only holds comments.
+			continue
+		}
+		if u.GetAPIVersion() == "" || u.GetKind() == "" {
+			return nil, errors.Errorf("document %d is not a Kubernetes object: it has no apiVersion or kind", i)
+		}
+		if u.GetKind() != providerRevisionKind {
+			continue
+		}
+		if u.GetAPIVersion() != providerRevisionAPIVersion {
+			return nil, errors.Errorf("document %d is a ProviderRevision of unsupported apiVersion %q: must be %s", i, u.GetAPIVersion(), providerRevisionAPIVersion)
+		}
+		if u.GetName() == "" {
+			return nil, errors.Errorf("document %d is a ProviderRevision without a metadata.name", i)
+		}
+		if u.GetLabels()[labelPackage] == "" {
+			return nil, errors.Errorf("document %d, ProviderRevision %q, has no %s label", i, u.GetName(), labelPackage)
+		}
+		prs = append(prs, u)
+	}
+}
+
+// selectList returns a copy of the supplied ProviderRevisions the supplied
+// options select. It returns every ProviderRevision when the options ask for
+// the metadata of those they do not select.
+func selectList(l *unstructured.UnstructuredList, o listOptions) *unstructured.UnstructuredList {
+	out := &unstructured.UnstructuredList{Items: make([]unstructured.Unstructured, 0, len(l.Items))}
+	for i := range l.Items {
+		if o.Selector != nil && !o.MetadataOnly && !o.Selector.Matches(labels.Set(l.Items[i].GetLabels())) {
+			continue
+		}
+		out.Items = append(out.Items, *l.Items[i].DeepCopy())
+	}
+	return out
+}
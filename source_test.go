@@ -0,0 +1,171 @@
+package main
+
+import (
+	// Standard library imports
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	// Imports with prefix github.com/crossplane
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+)
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"providers.yaml": `apiVersion: pkg.crossplane.io/v1
+kind: ProviderRevision
+metadata:
+  name: provider-kubernetes-aaa
+  labels:
+    pkg.crossplane.io/package: provider-kubernetes
+---
+apiVersion: pkg.crossplane.io/v1
+kind: Provider
+metadata:
+  name: provider-kubernetes
+---
+apiVersion: pkg.crossplane.io/v1
+kind: ProviderRevision
+metadata:
+  name: provider-helm-aaa
+  labels:
+    pkg.crossplane.io/package: provider-helm
+`,
+		"family.json": `{"apiVersion": "pkg.crossplane.io/v1", "kind": "ProviderRevision", "metadata": {"name": "provider-family-azure-aaa", "labels": {"pkg.crossplane.io/package": "provider-family-azure"}}}`,
+		"README.md":   "Not a manifest.",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string]struct {
+		reason string
+		path   string
+		opts   listOptions
+		want   []string
+	}{
+		"Directory": {
+			reason: "Every ProviderRevision of every manifest in the directory should be read.",
+			path:   dir,
+			want:   []string{"provider-family-azure-aaa", "provider-kubernetes-aaa", "provider-helm-aaa"},
+		},
+		"File": {
+			reason: "Every ProviderRevision of the file should be read.",
+			path:   filepath.Join(dir, "providers.yaml"),
+			want:   []string{"provider-kubernetes-aaa", "provider-helm-aaa"},
+		},
+		"Selector": {
+			reason: "Only the selected ProviderRevisions should be returned.",
+			path:   dir,
+			opts:   listOptions{Selector: labels.SelectorFromSet(labels.Set{labelPackage: "provider-helm"})},
+			want:   []string{"provider-helm-aaa"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s, err := newFileSource(tc.path)
+			if err != nil {
+				t.Fatalf("%s\nnewFileSource(...): %v", tc.reason, err)
+			}
+			l, err := s.ProviderRevisions(context.Background(), &fnv1.RunFunctionRequest{}, tc.opts)
+			if err != nil {
+				t.Fatalf("%s\ns.ProviderRevisions(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, names(l)); diff != "" {
+				t.Errorf("%s\ns.ProviderRevisions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDecodeProviderRevisions(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		stream string
+		want   []string
+		err    string
+	}{
+		"Stream": {
+			reason: "ProviderRevisions should be decoded, ignoring comments and other kinds.",
+			stream: `# ProviderRevisions of a cluster.
+---
+apiVersion: pkg.crossplane.io/v1
+kind: Provider
+metadata:
+  name: provider-kubernetes
+---
+apiVersion: pkg.crossplane.io/v1
+kind: ProviderRevision
+metadata:
+  name: provider-kubernetes-aaa
+  labels:
+    pkg.crossplane.io/package: provider-kubernetes
+`,
+			want: []string{"provider-kubernetes-aaa"},
+		},
+		"InvalidYAML": {
+			reason: "A document that is not valid YAML should be named.",
+			stream: "apiVersion: pkg.crossplane.io/v1\nkind: Provider\n---\nkind: [ProviderRevision\n",
+			err:    "document 2 is not valid YAML or JSON",
+		},
+		"NotAnObject": {
+			reason: "A document without apiVersion or kind should be named.",
+			stream: "metadata:\n  name: provider-kubernetes-aaa\n",
+			err:    "document 1 is not a Kubernetes object: it has no apiVersion or kind",
+		},
+		"UnsupportedAPIVersion": {
+			reason: "A ProviderRevision of another apiVersion should be named.",
+			stream: "apiVersion: pkg.crossplane.io/v1beta1\nkind: ProviderRevision\nmetadata:\n  name: provider-kubernetes-aaa\n",
+			err:    `document 1 is a ProviderRevision of unsupported apiVersion "pkg.crossplane.io/v1beta1": must be pkg.crossplane.io/v1`,
+		},
+		"NoPackageLabel": {
+			reason: "A ProviderRevision without a package label should be named.",
+			stream: "apiVersion: pkg.crossplane.io/v1\nkind: ProviderRevision\nmetadata:\n  name: provider-kubernetes-aaa\n",
+			err:    `document 1, ProviderRevision "provider-kubernetes-aaa", has no pkg.crossplane.io/package label`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			prs, err := decodeProviderRevisions(strings.NewReader(tc.stream))
+			if tc.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+					t.Errorf("%s\ndecodeProviderRevisions(...): want error %q, got %v", tc.reason, tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s\ndecodeProviderRevisions(...): %v", tc.reason, err)
+			}
+			got := names(&unstructured.UnstructuredList{Items: prs})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("%s\ndecodeProviderRevisions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// A fakeSource supplies in-memory ProviderRevisions.
+type fakeSource struct {
+	List *unstructured.UnstructuredList
+	Err  error
+}
+
+// ProviderRevisions returns a copy of the in-memory ProviderRevisions.
+func (s *fakeSource) ProviderRevisions(_ context.Context, _ *fnv1.RunFunctionRequest, o listOptions) (*unstructured.UnstructuredList, error) {
+	if s.Err != nil || s.List == nil {
+		return nil, s.Err
+	}
+	return selectList(s.List, o), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	// Standard library imports
+	"fmt"
+
+	// Default imports (third-party packages not matching other prefixes)
+	corev1 "k8s.io/api/core/v1"
+
+	// Imports with prefix github.com/crossplane
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+
+	// Imports with prefix github.com/crossplane-contrib
+	"github.com/crossplane-contrib/provider-kubernetes/apis/object/v1alpha2"
+)
+
+// A bindingStatus summarizes a binding on the composite resource status.
+type bindingStatus struct {
+	// Package of the bound provider. Unknown for a binding kept only by name.
+	Package string `json:"package,omitempty"`
+
+	// Revision is the name of the bound ProviderRevision. Unknown for a
+	// binding kept only by name.
+	Revision string `json:"revision,omitempty"`
+
+	// Role bound. Unknown for a binding kept only by name.
+	Role string `json:"role,omitempty"`
+
+	// Binding is the name of the ClusterRoleBinding.
+	Binding string `json:"binding"`
+
+	// Ready is true once the composed resource of the binding is ready.
+	Ready bool `json:"ready"`
+}
+
+// bindingStatuses summarizes the supplied bindings, whose readiness is read
+// from the supplied observed composed resources.
+func bindingStatuses(bindings []binding, observed map[resource.Name]resource.ObservedComposed) []bindingStatus {
+	out := make([]bindingStatus, 0, len(bindings))
+	for _, b := range bindings {
+		r, _ := bindingReadiness(b, observed)
+		out = append(out, bindingStatus{
+			Package:  b.Revision.Package,
+			Revision: b.Revision.GetName(),
+			Role:     b.Role.Name,
+			Binding:  b.Name,
+			Ready:    r == readinessReady,
+		})
+	}
+	return out
+}
+
+// keptBindingStatuses summarizes the bindings of the supplied observed
+// composed resources that are kept unchanged: those of the supplied summary
+// written earlier, then those missing from it by name and readiness only.
+func keptBindingStatuses(summary []bindingStatus, observed map[resource.Name]resource.ObservedComposed) []bindingStatus {
+	summarized := map[string]bool{}
+	for _, s := range summary {
+		summarized[s.Binding] = true
+	}
+
+	kept, unready := map[string]bool{}, map[string]bool{}
+	for _, oc := range observed {
+		b, ok := oc.Resource.GetLabels()[labelBinding]
+		if !ok || summarized[b] {
+			continue
+		}
+		kept[b] = true
+		if r, _ := getReadiness(oc.Resource); r != readinessReady {
+			unready[b] = true
+		}
+	}
+
+	out := append(make([]bindingStatus, 0, len(summary)+len(kept)), summary...)
+	for _, b := range sortedKeys(kept) {
+		out = append(out, bindingStatus{Binding: b, Ready: !unready[b]})
+	}
+	return out
+}
+
+// The readiness of a composed resource.
+type readiness int
+
+// Composed resources are ready, pending while they are not observed or not
+// ready yet, or failing when they cannot be applied. The order matters: the
+// readiness of a binding is the highest of those of its composed resources.
+const (
+	readinessReady readiness = iota
+	readinessPending
+	readinessFailing
+)
+
+// getReadiness returns the readiness of the supplied observed composed
+// resource, and why it is failing. A provider-kubernetes Object is failing
+// when it is not synced, like when provider-kubernetes may not bind the role,
+// and ready when its Ready condition is true. An RBAC resource composed
+// directly has no conditions, and is ready once observed.
+func getReadiness(oc *composed.Unstructured) (readiness, string) {
+	cs := xpv1.ConditionedStatus{}
+	_ = fieldpath.Pave(oc.Object).GetValueInto("status", &cs)
+	if len(cs.Conditions) == 0 && oc.GetKind() != v1alpha2.ObjectKind {
+		return readinessReady, ""
+	}
+	if c := cs.GetCondition(xpv1.TypeSynced); c.Status == corev1.ConditionFalse {
+		return readinessFailing, c.Message
+	}
+	if cs.GetCondition(xpv1.TypeReady).Status == corev1.ConditionTrue {
+		return readinessReady, ""
+	}
+	return readinessPending, ""
+}
+
+// observedReadiness returns the readiness of the supplied observed composed
+// resource, and why it is failing. It is pending until it is observed.
+func observedReadiness(observed map[resource.Name]resource.ObservedComposed, name resource.Name) (readiness, string) {
+	oc, ok := observed[name]
+	if !ok {
+		return readinessPending, ""
+	}
+	return getReadiness(oc.Resource)
+}
+
+// bindingReadiness returns the readiness of the composed resources of the
+// supplied binding, and why they are failing.
+func bindingReadiness(b binding, observed map[resource.Name]resource.ObservedComposed) (readiness, string) {
+	r, msg := observedReadiness(observed, resource.Name(b.Name))
+	if b.ClusterRoleResourceName == "" {
+		return r, msg
+	}
+	if cr, crmsg := observedReadiness(observed, resource.Name(b.ClusterRoleResourceName)); cr > r {
+		return cr, crmsg
+	}
+	return r, msg
+}
+
+// setReadiness explicitly marks the composed resources of bindings in the
+// supplied desired composed resources ready or not, per the supplied observed
+// composed resources. Other desired composed resources are left untouched.
+func setReadiness(desired map[resource.Name]*resource.DesiredComposed, observed map[resource.Name]resource.ObservedComposed) {
+	for name, dc := range desired {
+		if _, ok := dc.Resource.GetLabels()[labelBinding]; !ok {
+			continue
+		}
+		dc.Ready = resource.ReadyFalse
+		if r, _ := observedReadiness(observed, name); r == readinessReady {
+			dc.Ready = resource.ReadyTrue
+		}
+	}
+}
+
+// unreadyBindings returns the names of the supplied bindings that are failing,
+// with why when known, and of those that are pending.
+func unreadyBindings(bindings []binding, observed map[resource.Name]resource.ObservedComposed) (failing, pending []string) {
+	for _, b := range bindings {
+		switch r, msg := bindingReadiness(b, observed); {
+		case r == readinessFailing && msg != "":
+			failing = append(failing, fmt.Sprintf("%s (%s)", b.Name, msg))
+		case r == readinessFailing:
+			failing = append(failing, b.Name)
+		case r == readinessPending:
+			pending = append(pending, b.Name)
+		}
+	}
+	return failing, pending
+}
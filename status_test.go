@@ -0,0 +1,151 @@
+package main
+
+import (
+	// Standard library imports
+	"testing"
+
+	// Default imports (third-party packages not matching other prefixes)
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/resource/composed"
+)
+
+// withConditions returns the supplied observed composed resource with the
+// supplied status conditions.
+func withConditions(oc resource.ObservedComposed, conditions ...map[string]interface{}) resource.ObservedComposed {
+	cs := make([]interface{}, 0, len(conditions))
+	for _, c := range conditions {
+		cs = append(cs, c)
+	}
+	_ = unstructured.SetNestedSlice(oc.Resource.Object, cs, "status", "conditions")
+	return oc
+}
+
+func TestGetReadiness(t *testing.T) {
+	synced := map[string]interface{}{"type": "Synced", "status": "True", "reason": "ReconcileSuccess"}
+	unsynced := map[string]interface{}{"type": "Synced", "status": "False", "reason": "ReconcileError", "message": "cannot create object"}
+	ready := map[string]interface{}{"type": "Ready", "status": "True", "reason": "Available"}
+	creating := map[string]interface{}{"type": "Ready", "status": "False", "reason": "Creating"}
+
+	type want struct {
+		r   readiness
+		msg string
+	}
+
+	cases := map[string]struct {
+		reason string
+		oc     resource.ObservedComposed
+		want   want
+	}{
+		"ObjectWithoutConditions": {
+			reason: "An Object provider-kubernetes did not reconcile yet should be pending.",
+			oc:     observedObject("xr-abcde", "b"),
+			want:   want{r: readinessPending},
+		},
+		"ObjectReady": {
+			reason: "A synced and ready Object should be ready.",
+			oc:     withConditions(observedObject("xr-abcde", "b"), synced, ready),
+			want:   want{r: readinessReady},
+		},
+		"ObjectCreating": {
+			reason: "A synced Object that is not ready yet should be pending.",
+			oc:     withConditions(observedObject("xr-abcde", "b"), synced, creating),
+			want:   want{r: readinessPending},
+		},
+		"ObjectNotSynced": {
+			reason: "An Object that is not synced should be failing, with the message of its Synced condition.",
+			oc:     withConditions(observedObject("xr-abcde", "b"), unsynced, ready),
+			want:   want{r: readinessFailing, msg: "cannot create object"},
+		},
+		"DirectClusterRoleBinding": {
+			reason: "A ClusterRoleBinding composed directly has no conditions and should be ready once observed.",
+			oc: resource.ObservedComposed{Resource: &composed.Unstructured{Unstructured: unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "ClusterRoleBinding",
+				"metadata":   map[string]interface{}{"name": "b"},
+			}}}},
+			want: want{r: readinessReady},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r, msg := getReadiness(tc.oc.Resource)
+			if diff := cmp.Diff(tc.want, want{r: r, msg: msg}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("%s\ngetReadiness(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestUnreadyBindings(t *testing.T) {
+	ready := map[string]interface{}{"type": "Ready", "status": "True", "reason": "Available"}
+	unsynced := map[string]interface{}{"type": "Synced", "status": "False", "reason": "ReconcileError", "message": "forbidden"}
+
+	bindings := []binding{
+		{Name: "dev-team-provider-kubernetes-edit"},
+		{Name: "dev-team-provider-helm-edit"},
+		{Name: "dev-team-provider-aws-usage", ClusterRoleResourceName: "dev-team-provider-aws-usage-clusterrole"},
+		{Name: "dev-team-provider-gcp-edit"},
+	}
+	observed := map[resource.Name]resource.ObservedComposed{
+		"dev-team-provider-kubernetes-edit":       withConditions(observedObject("xr-abcde", "dev-team-provider-kubernetes-edit"), ready),
+		"dev-team-provider-helm-edit":             withConditions(observedObject("xr-fghij", "dev-team-provider-helm-edit"), unsynced),
+		"dev-team-provider-aws-usage":             withConditions(observedObject("xr-klmno", "dev-team-provider-aws-usage"), ready),
+		"dev-team-provider-aws-usage-clusterrole": withConditions(observedObject("xr-pqrst", "dev-team-provider-aws-usage"), unsynced),
+	}
+
+	failing, pending := unreadyBindings(bindings, observed)
+	if diff := cmp.Diff([]string{"dev-team-provider-helm-edit (forbidden)", "dev-team-provider-aws-usage (forbidden)"}, failing); diff != "" {
+		t.Errorf("unreadyBindings(...): -want failing, +got failing:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"dev-team-provider-gcp-edit"}, pending); diff != "" {
+		t.Errorf("unreadyBindings(...): -want pending, +got pending:\n%s", diff)
+	}
+
+	desired := map[resource.Name]*resource.DesiredComposed{
+		"dev-team-provider-kubernetes-edit": {Resource: observed["dev-team-provider-kubernetes-edit"].Resource},
+		"dev-team-provider-gcp-edit":        {Resource: observedObject("", "dev-team-provider-gcp-edit").Resource},
+		"other":                             {Resource: observedObject("", "").Resource, Ready: resource.ReadyUnspecified},
+	}
+	setReadiness(desired, observed)
+	got := map[resource.Name]resource.Ready{}
+	for name, dc := range desired {
+		got[name] = dc.Ready
+	}
+	want := map[resource.Name]resource.Ready{
+		"dev-team-provider-kubernetes-edit": resource.ReadyTrue,
+		"dev-team-provider-gcp-edit":        resource.ReadyFalse,
+		"other":                             resource.ReadyUnspecified,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("setReadiness(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestKeptBindingStatuses(t *testing.T) {
+	ready := map[string]interface{}{"type": "Ready", "status": "True", "reason": "Available"}
+
+	observed := map[resource.Name]resource.ObservedComposed{
+		"dev-team-provider-kubernetes-edit":       withConditions(observedObject("xr-abcde", "dev-team-provider-kubernetes-edit"), ready),
+		"dev-team-provider-helm-edit":             withConditions(observedObject("xr-fghij", "dev-team-provider-helm-edit"), ready),
+		"dev-team-provider-aws-usage":             withConditions(observedObject("xr-klmno", "dev-team-provider-aws-usage"), ready),
+		"dev-team-provider-aws-usage-clusterrole": observedObject("xr-pqrst", "dev-team-provider-aws-usage"),
+		"other": observedObject("xr-uvwxy", ""),
+	}
+	summary := []bindingStatus{
+		{Package: "provider-kubernetes", Revision: "provider-kubernetes-aaa", Role: "edit", Binding: "dev-team-provider-kubernetes-edit", Ready: true},
+	}
+
+	want := []bindingStatus{
+		{Package: "provider-kubernetes", Revision: "provider-kubernetes-aaa", Role: "edit", Binding: "dev-team-provider-kubernetes-edit", Ready: true},
+		{Binding: "dev-team-provider-aws-usage"},
+		{Binding: "dev-team-provider-helm-edit", Ready: true},
+	}
+	if diff := cmp.Diff(want, keptBindingStatuses(summary, observed)); diff != "" {
+		t.Errorf("keptBindingStatuses(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	// Standard library imports
+	"regexp"
+	"strings"
+
+	// Default imports (third-party packages not matching other prefixes)
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	// Imports with prefix github.com/crossplane
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/function-sdk-go/errors"
+	"github.com/crossplane/function-sdk-go/resource/composite"
+
+	"github.com/chelala/function-fluxcd-tenant-crossplane-providers-usage-resource-crbs/input/v1beta1"
+)
+
+// getSubjects returns the subjects bound to the roles of the tenant, read
+// from the supplied composite resource. The tenant ServiceAccount is bound in
+// every namespace at the input NamespacesFieldPath, or in the namespace named
+// after the tenant when it is not set or the composite resource has no such
+// field. Any subjects at the input
+// SubjectsFieldPath are bound too.
+func getSubjects(xr *composite.Unstructured, in *v1beta1.Input, tenantName string) ([]rbacv1.Subject, error) {
+	namespaces := []string{tenantName}
+	if in.NamespacesFieldPath != "" {
+		ns, err := xr.GetStringArray(in.NamespacesFieldPath)
+		switch {
+		case fieldpath.IsNotFound(err):
+			// The tenant ServiceAccount stays in the tenant namespace.
+		case err != nil:
+			return nil, errors.Wrapf(err, "cannot get the tenant namespaces at %s", in.NamespacesFieldPath)
+		case len(ns) == 0:
+			// An empty list, like a schema default, is no namespaces either.
+		default:
+			namespaces = ns
+		}
+	}
+
+	subjects := make([]rbacv1.Subject, 0, len(namespaces))
+	for _, ns := range namespaces {
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: tenantName, Namespace: ns})
+	}
+
+	if in.SubjectsFieldPath != "" {
+		extra := []rbacv1.Subject{}
+		if err := xr.GetValueInto(in.SubjectsFieldPath, &extra); err != nil && !fieldpath.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "cannot get the tenant subjects at %s", in.SubjectsFieldPath)
+		}
+		for i := range extra {
+			if extra[i].Kind != rbacv1.ServiceAccountKind && extra[i].APIGroup == "" {
+				extra[i].APIGroup = rbacv1.GroupName
+			}
+		}
+		subjects = append(subjects, extra...)
+	}
+
+	out := make([]rbacv1.Subject, 0, len(subjects))
+	seen := map[rbacv1.Subject]bool{}
+	for _, s := range subjects {
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		if err := validateSubject(s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("the tenant has no subjects to bind")
+	}
+	return out, nil
+}
+
+// validateSubject returns an error explaining why the supplied subject cannot
+// be bound.
+func validateSubject(s rbacv1.Subject) error {
+	switch s.Kind {
+	case rbacv1.ServiceAccountKind:
+		if msgs := validation.IsDNS1123Label(s.Namespace); len(msgs) > 0 {
+			return errors.Errorf("namespace %q of ServiceAccount %q is not a valid namespace name: %s", s.Namespace, s.Name, strings.Join(msgs, "; "))
+		}
+		if msgs := validation.IsDNS1123Subdomain(s.Name); len(msgs) > 0 {
+			return errors.Errorf("ServiceAccount name %q is not valid: %s", s.Name, strings.Join(msgs, "; "))
+		}
+	case rbacv1.UserKind, rbacv1.GroupKind:
+		if s.Name == "" {
+			return errors.Errorf("%s subject has no name", s.Kind)
+		}
+		if s.Namespace != "" {
+			return errors.Errorf("%s subject %q must not have a namespace", s.Kind, s.Name)
+		}
+	default:
+		return errors.Errorf("subject %q has unsupported kind %q: must be one of %s, %s or %s", s.Name, s.Kind, rbacv1.ServiceAccountKind, rbacv1.UserKind, rbacv1.GroupKind)
+	}
+	return nil
+}
+
+// invalidNameChars matches the characters replaced when a subject is used in a
+// generated name.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// subjectNameSuffix returns the suffix of the name of a binding of the
+// supplied subject only, so that the bindings of each subject are distinct.
+func subjectNameSuffix(s rbacv1.Subject) string {
+	id := s.Name
+	if s.Kind == rbacv1.ServiceAccountKind {
+		id = s.Namespace + "-" + s.Name
+	}
+	return strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(id), "-"), "-")
+}